@@ -22,7 +22,7 @@ func main() {
 	}
 
 	//Create AppConfig will all initialization
-	appConfig := app.SetAppConfig()
+	appConfig := app.SetAppConfig(ctx)
 
 	srv := &http.Server{
 		Addr:    addr,
@@ -45,6 +45,7 @@ func main() {
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Printf("graceful shutdown error: %v", err)
 		}
+		appConfig.Shutdown()
 		// Cancel root context so ALL goroutines & requests stop
 		log.Println("Shutdown done...Cancelling all goroutines which are still running")
 		rootCancel()
@@ -1,14 +1,18 @@
 package app
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/example/mini-hotel-aggregator/internal/fx"
 	handlers "github.com/example/mini-hotel-aggregator/internal/http"
 	"github.com/example/mini-hotel-aggregator/internal/obs"
 	"github.com/example/mini-hotel-aggregator/internal/providers"
+	"github.com/example/mini-hotel-aggregator/internal/resp"
 	"github.com/example/mini-hotel-aggregator/internal/routes"
 	"github.com/example/mini-hotel-aggregator/internal/search"
 	"github.com/prometheus/client_golang/prometheus"
@@ -20,9 +24,22 @@ type App struct {
 	Cache       search.CacheService
 	RateLimiter search.RateLimiter
 	Metrics     *obs.Metrics
+
+	// shutdown hooks run by Shutdown once the server stopped serving
+	shutdown []func()
+}
+
+// Shutdown runs the shutdown hooks, e.g. saving the cache snapshot. Call it
+// after the HTTP server has drained.
+func (a *App) Shutdown() {
+	for _, fn := range a.shutdown {
+		fn()
+	}
 }
 
-func SetAppConfig() *App {
+// SetAppConfig wires all components. Background loops (e.g. FX refresh) run
+// until ctx is cancelled.
+func SetAppConfig(ctx context.Context) *App {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 	providersList := []search.Provider{
@@ -31,20 +48,171 @@ func SetAppConfig() *App {
 		providers.NewMockProvider("mock3", 0.15, 0.05, 2),
 	}
 
+	// real suppliers are described in a JSON file, see providers.HTTPProviderConfig
+	if path := os.Getenv("PROVIDERS_CONFIG"); path != "" {
+		cfgs, err := providers.LoadHTTPProviderConfigs(path)
+		if err != nil {
+			logger.Error("loading provider config failed", "path", path, "error", err)
+		}
+		client := &http.Client{}
+		for _, cfg := range cfgs {
+			p, err := providers.NewHTTPProvider(cfg, client)
+			if err != nil {
+				logger.Error("skipping http provider", "name", cfg.Name, "error", err)
+				continue
+			}
+			providersList = append(providersList, p)
+		}
+	}
+
+	var mappings search.HotelMappings
+	if path := os.Getenv("HOTEL_MAPPINGS"); path != "" {
+		m, err := search.LoadHotelMappings(path)
+		if err != nil {
+			logger.Error("loading hotel mappings failed", "path", path, "error", err)
+		}
+		mappings = m
+	}
+
+	// provider answers never outlive the results built from them
+	const cacheTTL = 30 * time.Second
+	aggOpts := []search.AggregatorOption{
+		search.WithCircuitBreaker(search.DefaultBreakerConfig()),
+		search.WithRetry(search.DefaultRetryPolicy()),
+		search.WithHotelResolver(search.NewHotelResolver(mappings, 0.75)),
+		search.WithProviderCache(cacheTTL),
+	}
+	// hedging trades extra provider calls for tail latency, so it is opt-in
+	if on, _ := strconv.ParseBool(os.Getenv("PROVIDER_HEDGING")); on {
+		aggOpts = append(aggOpts, search.WithHedging(search.DefaultHedgeConfig()))
+	}
+
+	// prices are only converted when a rates file is configured
+	if path := os.Getenv("FX_RATES"); path != "" {
+		conv, err := fx.NewConverter(ctx, fx.FileSource{Path: path, Base: "EUR"})
+		if err != nil {
+			logger.Error("loading fx rates failed, currency conversion disabled", "path", path, "error", err)
+		} else {
+			go conv.Run(ctx, 15*time.Minute)
+			currency := os.Getenv("DISPLAY_CURRENCY")
+			if currency == "" {
+				currency = "EUR"
+			}
+			aggOpts = append(aggOpts, search.WithCurrencyConverter(conv, currency))
+		}
+	}
+
 	customRegistry := prometheus.NewRegistry()
 	metrics := obs.NewMetrics(customRegistry)
-	agg := search.NewAggregator(providersList, 2*time.Second, metrics)
-	cache := search.NewCache(30*time.Second, metrics)
-	rl := search.NewIPRateLimiter(10, time.Minute)
-	h := handlers.NewHandler(agg, cache, rl, metrics)
+	agg := search.NewAggregator(providersList, 2*time.Second, metrics, aggOpts...)
+	go agg.Run(ctx, time.Minute)
+	cache := search.NewCache(cacheTTL, metrics,
+		search.WithStaleWhileRevalidate(2*time.Minute),
+		search.WithStaleIfError(10*time.Minute),
+		search.WithNegativeTTL(5*time.Second),
+		search.WithDegradedTTL(5*time.Second),
+		search.WithMaxEntries(search.DefaultCacheMaxEntries),
+		search.WithMaxBytes(search.DefaultCacheMaxBytes),
+	)
+	go cache.Run(ctx, time.Minute)
+
+	// the local cache survives restarts through a snapshot file
+	var shutdown []func()
+	if path := os.Getenv("CACHE_SNAPSHOT"); path != "" {
+		n, err := cache.LoadSnapshot(path)
+		if err != nil {
+			logger.Error("loading cache snapshot failed", "path", path, "error", err)
+		} else {
+			logger.Info("cache snapshot loaded", "path", path, "entries", n)
+		}
+		shutdown = append(shutdown, func() {
+			n, err := cache.SaveSnapshot(path)
+			if err != nil {
+				logger.Error("saving cache snapshot failed", "path", path, "error", err)
+				return
+			}
+			logger.Info("cache snapshot saved", "path", path, "entries", n)
+		})
+	}
+
+	// with a shared backend replicas share results; the local cache is only
+	// used while the backend is unreachable
+	var searchCache search.CacheService = cache
+	var redisClient *resp.Client
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		redisClient = resp.NewClient(addr)
+		searchCache = search.NewRedisCache(redisClient, cache, cacheTTL, metrics,
+			search.WithBackendStaleWhileRevalidate(2*time.Minute),
+			search.WithBackendStaleIfError(10*time.Minute),
+			search.WithBackendNegativeTTL(5*time.Second),
+			search.WithBackendDegradedTTL(5*time.Second),
+		)
+	}
+	overflow := os.Getenv("RATE_LIMIT_OVERFLOW")
+	switch overflow {
+	case search.OverflowEvictOldest, search.OverflowReject, search.OverflowAllow:
+	default:
+		if overflow != "" {
+			logger.Error("invalid rate limit overflow policy, evicting oldest", "policy", overflow)
+		}
+		overflow = search.OverflowEvictOldest
+	}
+	limiterOpts := []search.LimiterOption{
+		search.WithMaxKeys(search.DefaultRateLimitMaxKeys, overflow),
+		search.WithLimiterMetrics(metrics),
+	}
+	rl, err := search.NewRateLimiter(os.Getenv("RATE_LIMIT_ALGORITHM"), limiterOpts...)
+	if err != nil {
+		logger.Error("invalid rate limit algorithm, using token bucket", "error", err)
+		rl, _ = search.NewRateLimiter(search.RateLimitTokenBucket, limiterOpts...)
+	}
+	go rl.Run(ctx, time.Minute)
+
+	// with a shared store replicas share one budget per client; the local
+	// limiter covers outages under the default store failure policy
+	var limiter search.RateLimiter = rl
+	if redisClient != nil {
+		failure := os.Getenv("RATE_LIMIT_STORE_FAILURE")
+		switch failure {
+		case search.StoreFailureLocal, search.StoreFailureOpen, search.StoreFailureClosed:
+		default:
+			if failure != "" {
+				logger.Error("invalid rate limit store failure policy, using the local limiter", "policy", failure)
+			}
+			failure = search.StoreFailureLocal
+		}
+		limiter = search.NewRedisLimiter(redisClient, rl, metrics, search.WithStoreFailure(failure))
+	}
+
+	// partners authenticate with X-API-Key, see config/api_keys.example.json
+	var apiKeys *search.APIKeyStore
+	if path := os.Getenv("API_KEYS"); path != "" {
+		apiKeys, err = search.LoadAPIKeyStore(path)
+		if err != nil {
+			logger.Error("loading api keys failed", "path", path, "error", err)
+		}
+	}
+	h := handlers.NewHandler(agg, searchCache, metrics)
+
+	// popular searches are recomputed ahead of expiry, see config/warm_searches.example.json
+	if path := os.Getenv("WARM_SEARCHES"); path != "" {
+		templates, err := search.LoadWarmTemplates(path)
+		if err != nil {
+			logger.Error("loading warm searches failed", "path", path, "error", err)
+		} else {
+			svc := search.NewService(agg, searchCache, metrics, 3*time.Second)
+			go search.NewWarmer(svc, templates, 4, metrics).Run(ctx, 25*time.Second)
+		}
+	}
 
-	router := routes.GetRoutes(h, metrics, logger)
+	router := routes.GetRoutes(h, metrics, logger, os.Getenv("ADMIN_TOKEN"), limiter, apiKeys)
 
 	return &App{
 		Router:      router,
 		Aggregator:  agg,
-		Cache:       cache,
-		RateLimiter: rl,
+		Cache:       searchCache,
+		RateLimiter: limiter,
 		Metrics:     metrics,
+		shutdown:    shutdown,
 	}
 }
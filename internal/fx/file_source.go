@@ -0,0 +1,74 @@
+package fx
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileSource reads rates from a local file, re-reading it on every Load so
+// the file can be updated in place.
+//
+// JSON files look like {"base":"EUR","rates":{"USD":1.08}}. CSV files hold
+// "currency,rate" rows (an optional header is skipped) relative to Base.
+type FileSource struct {
+	Path string
+	// Base is the base currency for CSV files and the default for JSON files
+	// that omit it.
+	Base string
+}
+
+func (f FileSource) Load(ctx context.Context) (Rates, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return Rates{}, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(f.Path)) {
+	case ".csv":
+		return f.loadCSV(file)
+	case ".json":
+		return f.loadJSON(file)
+	}
+	return Rates{}, fmt.Errorf("fx: unsupported rates file %s", f.Path)
+}
+
+func (f FileSource) loadJSON(r io.Reader) (Rates, error) {
+	var rates Rates
+	if err := json.NewDecoder(r).Decode(&rates); err != nil {
+		return Rates{}, fmt.Errorf("fx: parse %s: %w", f.Path, err)
+	}
+	if rates.Base == "" {
+		rates.Base = f.Base
+	}
+	return rates, nil
+}
+
+func (f FileSource) loadCSV(r io.Reader) (Rates, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return Rates{}, fmt.Errorf("fx: parse %s: %w", f.Path, err)
+	}
+	rates := Rates{Base: f.Base, Rates: make(map[string]float64, len(records))}
+	for i, rec := range records {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return Rates{}, fmt.Errorf("fx: %s line %d: invalid rate %q", f.Path, i+1, rec[1])
+		}
+		rates.Rates[strings.TrimSpace(rec[0])] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,132 @@
+// Package fx converts prices between currencies using exchange rates from a
+// pluggable RateSource.
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// Rates holds how many units of each currency one unit of Base buys.
+type Rates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// RateSource loads a full rate table. Implementations may read files, call
+// an API or return fixed rates.
+type RateSource interface {
+	Load(ctx context.Context) (Rates, error)
+}
+
+// Converter converts amounts using the last rates loaded from its source.
+// It is safe for concurrent use.
+type Converter struct {
+	mu       sync.RWMutex
+	src      RateSource
+	rates    Rates
+	loadedAt time.Time
+}
+
+// NewConverter loads the initial rate table; it fails if that load fails so
+// the service never starts without rates.
+func NewConverter(ctx context.Context, src RateSource) (*Converter, error) {
+	c := &Converter{src: src}
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Refresh reloads the rates. On failure the previous table is kept.
+func (c *Converter) Refresh(ctx context.Context) error {
+	r, err := c.src.Load(ctx)
+	if err != nil {
+		return err
+	}
+	r, err = normalize(r)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.rates = r
+	c.loadedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// Run refreshes the rates every interval until ctx is cancelled.
+func (c *Converter) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := c.Refresh(ctx); err != nil {
+				log.Printf("fx refresh failed, keeping rates from %s: %v", c.LoadedAt().Format(time.RFC3339), err)
+			}
+		}
+	}
+}
+
+func (c *Converter) LoadedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loadedAt
+}
+
+// Supports reports whether code can be converted to and from.
+func (c *Converter) Supports(code string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.rates.Rates[strings.ToUpper(code)]
+	return ok
+}
+
+// Convert converts amount from one currency to another, rounded to cents.
+func (c *Converter) Convert(amount float64, from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+	c.mu.RLock()
+	fromRate, okFrom := c.rates.Rates[from]
+	toRate, okTo := c.rates.Rates[to]
+	c.mu.RUnlock()
+	if !okFrom {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, from)
+	}
+	if !okTo {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, to)
+	}
+	v := amount / fromRate * toRate
+	return math.Round(v*100) / 100, nil
+}
+
+// normalize upper-cases codes, checks rates are usable and makes sure the
+// base currency converts to itself.
+func normalize(r Rates) (Rates, error) {
+	base := strings.ToUpper(strings.TrimSpace(r.Base))
+	if base == "" {
+		return Rates{}, errors.New("fx: missing base currency")
+	}
+	out := Rates{Base: base, Rates: make(map[string]float64, len(r.Rates)+1)}
+	for code, rate := range r.Rates {
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return Rates{}, fmt.Errorf("fx: invalid rate %v for %s", rate, code)
+		}
+		out.Rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	out.Rates[base] = 1
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/mini-hotel-aggregator/internal/fx"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConverter_JSONSource(t *testing.T) {
+	path := writeFile(t, "rates.json", `{"base":"EUR","rates":{"usd":1.10,"MAD":11.0}}`)
+	c, err := fx.NewConverter(context.Background(), fx.FileSource{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		amount   float64
+		from, to string
+		want     float64
+	}{
+		{100, "EUR", "USD", 110},
+		{110, "USD", "EUR", 100},
+		{110, "mad", "usd", 11},
+		{42.5, "EUR", "EUR", 42.5},
+		{10, "USD", "USD", 10},
+	}
+	for _, tt := range tests {
+		got, err := c.Convert(tt.amount, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s->%s: %v", tt.from, tt.to, err)
+		}
+		if got != tt.want {
+			t.Errorf("%v %s->%s: expected %v, got %v", tt.amount, tt.from, tt.to, tt.want, got)
+		}
+	}
+
+	if _, err := c.Convert(1, "EUR", "GBP"); !errors.Is(err, fx.ErrUnknownCurrency) {
+		t.Fatalf("expected unknown currency error, got %v", err)
+	}
+	if !c.Supports("eur") || c.Supports("GBP") {
+		t.Fatal("unexpected Supports result")
+	}
+}
+
+func TestConverter_CSVSource(t *testing.T) {
+	path := writeFile(t, "rates.csv", "currency,rate\nUSD,1.25\nGBP, 0.5\n")
+	c, err := fx.NewConverter(context.Background(), fx.FileSource{Path: path, Base: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Convert(10, "GBP", "USD")
+	if err != nil || got != 25 {
+		t.Fatalf("expected 25, got %v (%v)", got, err)
+	}
+}
+
+func TestConverter_RefreshKeepsRatesOnFailure(t *testing.T) {
+	path := writeFile(t, "rates.json", `{"base":"EUR","rates":{"USD":2}}`)
+	c, err := fx.NewConverter(context.Background(), fx.FileSource{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"base":"EUR","rates":{"USD":4}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Convert(1, "EUR", "USD"); got != 4 {
+		t.Fatalf("expected refreshed rate, got %v", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"base":"EUR","rates":{"USD":-1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Refresh(context.Background()); err == nil {
+		t.Fatal("expected invalid rate to be rejected")
+	}
+	if got, _ := c.Convert(1, "EUR", "USD"); got != 4 {
+		t.Fatalf("expected previous rates to be kept, got %v", got)
+	}
+}
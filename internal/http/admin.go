@@ -0,0 +1,76 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListCache handles GET /admin/cache?prefix=...
+func (h *Handler) ListCache(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.cache.List(r.Context(), r.URL.Query().Get("prefix"))
+	if err != nil {
+		InternalError(w, err.Error(), nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"count": len(entries), "entries": entries})
+}
+
+// GetCacheEntry handles GET /admin/cache/entry?key=...
+func (h *Handler) GetCacheEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		BadRequest(w, "missing key", nil)
+		return
+	}
+	entry, ok, err := h.cache.Get(r.Context(), key)
+	if err != nil {
+		InternalError(w, err.Error(), nil)
+		return
+	}
+	if !ok {
+		NotFound(w, "no cache entry for key", map[string]string{"key": key})
+		return
+	}
+	WriteJSON(w, http.StatusOK, entry)
+}
+
+// DeleteCacheEntry handles DELETE /admin/cache/entry?key=...
+func (h *Handler) DeleteCacheEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		BadRequest(w, "missing key", nil)
+		return
+	}
+	ok, err := h.cache.Delete(r.Context(), key)
+	if err != nil {
+		InternalError(w, err.Error(), nil)
+		return
+	}
+	if !ok {
+		NotFound(w, "no cache entry for key", map[string]string{"key": key})
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]int{"deleted": 1})
+}
+
+// PurgeCity handles DELETE /admin/cache/cities/{city}.
+func (h *Handler) PurgeCity(w http.ResponseWriter, r *http.Request) {
+	n, err := h.cache.DeletePrefix(r.Context(), models.CityKeyPrefix(chi.URLParam(r, "city")))
+	if err != nil {
+		InternalError(w, err.Error(), nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]int{"deleted": n})
+}
+
+// FlushCache handles DELETE /admin/cache.
+func (h *Handler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	n, err := h.cache.Flush(r.Context())
+	if err != nil {
+		InternalError(w, err.Error(), nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]int{"deleted": n})
+}
@@ -0,0 +1,107 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	ht "github.com/example/mini-hotel-aggregator/internal/http"
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/routes"
+	"github.com/example/mini-hotel-aggregator/internal/search"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var romeRequest = &models.SearchRequest{City: "rome", Checkin: "2025-01-01", Nights: 1, Adults: 1}
+
+func newAdminServer(t *testing.T, token string) (http.Handler, search.CacheService) {
+	t.Helper()
+	metrics := obs.NewMetrics(prometheus.NewRegistry())
+	cache := search.NewCache(time.Minute, metrics)
+	fn := func(ctx context.Context) (search.AggregatedResult, error) {
+		return search.AggregatedResult{Hotels: []search.Hotel{{HotelID: "H1"}}}, nil
+	}
+	for _, req := range []*models.SearchRequest{
+		{City: "paris", Checkin: "2025-01-01", Nights: 1, Adults: 1},
+		{City: "paris", Checkin: "2025-01-02", Nights: 1, Adults: 1},
+		romeRequest,
+	} {
+		cache.GetOrCompute(context.Background(), req.CacheKey(), fn)
+	}
+	h := ht.NewHandler(&mockAggregator{}, cache, metrics)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return routes.GetRoutes(h, metrics, logger, token, search.NewTokenBucketLimiter(), nil), cache
+}
+
+func adminRequest(t *testing.T, srv http.Handler, method, target, token string) (*http.Response, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	var out map[string]any
+	json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Result(), out
+}
+
+func TestAdmin_Auth(t *testing.T) {
+	srv, _ := newAdminServer(t, "s3cret")
+
+	if resp, _ := adminRequest(t, srv, "GET", "/admin/cache", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", resp.StatusCode)
+	}
+	if resp, _ := adminRequest(t, srv, "GET", "/admin/cache", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with wrong token, got %d", resp.StatusCode)
+	}
+
+	disabled, _ := newAdminServer(t, "")
+	if resp, _ := adminRequest(t, disabled, "GET", "/admin/cache", "anything"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 when admin API is disabled, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdmin_CacheEndpoints(t *testing.T) {
+	srv, cache := newAdminServer(t, "s3cret")
+
+	romeKey := url.QueryEscape(romeRequest.CacheKey())
+
+	resp, out := adminRequest(t, srv, "GET", "/admin/cache?prefix="+url.QueryEscape(models.CityKeyPrefix("paris")), "s3cret")
+	if resp.StatusCode != http.StatusOK || out["count"] != 2.0 {
+		t.Fatalf("expected 2 paris entries, got %d %+v", resp.StatusCode, out)
+	}
+
+	resp, out = adminRequest(t, srv, "GET", "/admin/cache/entry?key="+romeKey, "s3cret")
+	if resp.StatusCode != http.StatusOK || out["key"] != romeRequest.CacheKey() || out["result"] == nil {
+		t.Fatalf("unexpected entry %d %+v", resp.StatusCode, out)
+	}
+	if resp, _ := adminRequest(t, srv, "GET", "/admin/cache/entry?key=nope", "s3cret"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown key, got %d", resp.StatusCode)
+	}
+
+	resp, out = adminRequest(t, srv, "DELETE", "/admin/cache/cities/Paris", "s3cret")
+	if resp.StatusCode != http.StatusOK || out["deleted"] != 2.0 {
+		t.Fatalf("expected 2 paris entries purged, got %d %+v", resp.StatusCode, out)
+	}
+
+	resp, _ = adminRequest(t, srv, "DELETE", "/admin/cache/entry?key="+romeKey, "s3cret")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected rome entry deleted, got %d", resp.StatusCode)
+	}
+
+	cache.GetOrCompute(context.Background(), "oslo|x", func(ctx context.Context) (search.AggregatedResult, error) {
+		return search.AggregatedResult{}, nil
+	})
+	resp, out = adminRequest(t, srv, "DELETE", "/admin/cache", "s3cret")
+	if resp.StatusCode != http.StatusOK || out["deleted"] != 1.0 {
+		t.Fatalf("expected flush to delete 1 entry, got %d %+v", resp.StatusCode, out)
+	}
+}
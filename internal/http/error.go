@@ -33,6 +33,10 @@ func InternalError(w http.ResponseWriter, msg string, meta map[string]string) {
     WriteError(w, http.StatusInternalServerError, msg, meta)
 }
 
+func BadGateway(w http.ResponseWriter, msg string, meta map[string]string) {
+    WriteError(w, http.StatusBadGateway, msg, meta)
+}
+
 func TooManyRequests(w http.ResponseWriter, msg string, meta map[string]string) {
     WriteError(w, http.StatusTooManyRequests, msg, meta)
 }
\ No newline at end of file
@@ -1,8 +1,10 @@
 package http
 
 import (
-	"net"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/example/mini-hotel-aggregator/internal/models"
@@ -14,23 +16,16 @@ import (
 type Handler struct {
 	agg            search.AggregatorService
 	cache          search.CacheService
-	ratelimiter    search.RateLimiter
 	metrics        *obs.Metrics
 	computeTimeout time.Duration
 	service        search.ServiceManagement
 }
 
-func NewHandler(agg search.AggregatorService, cache search.CacheService, rl search.RateLimiter, m *obs.Metrics) *Handler {
+// NewHandler builds the HTTP handlers. Rate limiting is applied by the
+// router, see middleware.RateLimit.
+func NewHandler(agg search.AggregatorService, cache search.CacheService, m *obs.Metrics) *Handler {
 	s := search.NewService(agg, cache, m, 3*time.Second)
-	return &Handler{agg: agg, cache: cache, ratelimiter: rl, metrics: m, computeTimeout: 3 * time.Second, service: s}
-}
-
-func (h *Handler) ipFromRequest(r *http.Request) string {
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
+	return &Handler{agg: agg, cache: cache, metrics: m, computeTimeout: 3 * time.Second, service: s}
 }
 
 func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
@@ -49,41 +44,86 @@ func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
 		q.Get("checkin"),
 		q.Get("nights"),
 		q.Get("adults"),
+		q.Get("currency"),
 	)
 	if err != nil {
 		BadRequest(w, err.Error(), map[string]string{"request_id": reqID})
 		return
 	}
-
-	if err := req.Validate(); err != nil {
+	req.Page, err = models.ParsePage(q.Get("sort"), q.Get("limit"), q.Get("cursor"))
+	if err != nil {
+		BadRequest(w, err.Error(), map[string]string{"request_id": reqID})
+		return
+	}
+	req.Filters, err = models.ParseFilters(
+		q.Get("min_price"),
+		q.Get("max_price"),
+		q.Get("stars"),
+		q.Get("amenities"),
+		q.Get("name_contains"),
+	)
+	if err != nil {
 		BadRequest(w, err.Error(), map[string]string{"request_id": reqID})
 		return
 	}
 
-	// rate limit
-	ip := h.ipFromRequest(r)
-	if !h.ratelimiter.Allow(ip) {
-		h.metrics.IncRateLimitDrops()
-		TooManyRequests(w, "rate limit exceeded", map[string]string{"request_id": reqID})
+	if err := req.Validate(); err != nil {
+		BadRequest(w, err.Error(), map[string]string{"request_id": reqID})
 		return
 	}
 
 	//passing request to service
 	res, err := h.service.Search(ctx, req)
+	if errors.Is(err, search.ErrUnsupportedCurrency) || errors.Is(err, search.ErrInvalidCursor) {
+		BadRequest(w, err.Error(), map[string]string{"request_id": reqID})
+		return
+	}
+	if errors.Is(err, search.ErrAllProvidersFailed) {
+		BadGateway(w, err.Error(), map[string]string{"request_id": reqID})
+		return
+	}
 	if err != nil {
 		InternalError(w, err.Error(), map[string]string{"request_id": reqID})
 		return
 	}
 
 	out := map[string]any{
-		"search": map[string]any{"city": req.City, "checkin": req.Checkin, "nights": req.Nights, "adults": req.Adults},
+		"search": map[string]any{"city": req.City, "checkin": req.Checkin, "nights": req.Nights, "adults": req.Adults, "currency": res.Currency},
 		"stats":  res.Stats,
 		"hotels": res.Hotels,
+		"page":   res.Page,
 	}
 
+	setCacheHeaders(w, res.Stats)
 	WriteJSON(w, http.StatusOK, out)
 }
 
+// cacheName identifies the search cache in Cache-Status headers.
+const cacheName = "hotel-aggregator"
+
+// setCacheHeaders reports how the result was served as Age and an RFC 9211
+// Cache-Status header.
+func setCacheHeaders(w http.ResponseWriter, st search.SearchStats) {
+	ttl := st.CacheTTLMs / 1000
+	var status string
+	switch st.Cache {
+	case search.CacheHit:
+		status = fmt.Sprintf("%s; hit; ttl=%d", cacheName, ttl)
+	case search.CacheStale:
+		status = fmt.Sprintf("%s; hit; ttl=%d; detail=stale-while-revalidate", cacheName, ttl)
+	case search.CacheStaleError:
+		status = fmt.Sprintf("%s; fwd=stale; ttl=%d; detail=stale-if-error", cacheName, ttl)
+	case search.CacheCoalesced:
+		status = fmt.Sprintf("%s; fwd=miss; stored; collapsed; ttl=%d", cacheName, ttl)
+	case search.CacheMiss:
+		status = fmt.Sprintf("%s; fwd=miss; stored; ttl=%d", cacheName, ttl)
+	default:
+		return
+	}
+	w.Header().Set("Cache-Status", status)
+	w.Header().Set("Age", strconv.FormatInt(max(st.CacheAgeMs, 0)/1000, 10))
+}
+
 func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
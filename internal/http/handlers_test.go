@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -25,21 +26,14 @@ func (m *mockAggregator) Search(ctx context.Context, req *models.SearchRequest)
 }
 
 type mockCache struct {
-	getOrComputeFunc func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error)
+	search.CacheService // admin methods are not exercised here
+	getOrComputeFunc    func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error)
 }
 
 func (m *mockCache) GetOrCompute(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error) {
 	return m.getOrComputeFunc(ctx, key, fn)
 }
 
-type mockRateLimiter struct {
-	allowFunc func(ip string) bool
-}
-
-func (m *mockRateLimiter) Allow(ip string) bool {
-	return m.allowFunc(ip)
-}
-
 func TestHandler_Search_Positive(t *testing.T) {
 	cache := &mockCache{
 		getOrComputeFunc: func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error) {
@@ -47,22 +41,15 @@ func TestHandler_Search_Positive(t *testing.T) {
 				Hotels: []search.Hotel{
 					{HotelID: "H1", Name: "A", Price: 100, Nights: 1},
 				},
-				Stats: struct {
-					ProvidersTotal     int    "json:\"providers_total\""
-					ProvidersSucceeded int    "json:\"providers_succeeded\""
-					ProvidersFailed    int    "json:\"providers_failed\""
-					Cache              string "json:\"cache\""
-					DurationMs         int64  "json:\"duration_ms\""
-				}{ProvidersTotal: 1, ProvidersSucceeded: 1, ProvidersFailed: 0, Cache: "miss", DurationMs: 50},
+				Stats: search.SearchStats{ProvidersTotal: 1, ProvidersSucceeded: 1, ProvidersFailed: 0, Cache: "miss", DurationMs: 50},
 			}, nil
 		},
 	}
 
 	agg := &mockAggregator{}
-	rl := &mockRateLimiter{allowFunc: func(ip string) bool { return true }}
 	metrics := obs.NewMetrics(prometheus.NewRegistry())
 
-	h := ht.NewHandler(agg, cache, rl, metrics)
+	h := ht.NewHandler(agg, cache, metrics)
 
 	req := httptest.NewRequest("GET", "/search?city=kota&checkin=2025-11-20&nights=2&adults=2", nil)
 	req.RemoteAddr = "1.2.3.4:1234"
@@ -87,15 +74,21 @@ func TestHandler_Search_ValidationFailures(t *testing.T) {
 		{"NightsNotNumber", "?city=abc&checkin=2025-01-01&nights=x&adults=2", http.StatusBadRequest},
 		{"NightsZero", "?city=abc&checkin=2025-01-01&nights=0&adults=2", http.StatusBadRequest},
 		{"AdultsZero", "?city=abc&checkin=2025-01-01&nights=2&adults=0", http.StatusBadRequest},
+		{"InvalidCurrency", "?city=abc&checkin=2025-01-01&nights=2&adults=2&currency=EURO", http.StatusBadRequest},
+		{"MinPriceNotNumber", "?city=abc&checkin=2025-01-01&nights=2&adults=2&min_price=cheap", http.StatusBadRequest},
+		{"MinAboveMaxPrice", "?city=abc&checkin=2025-01-01&nights=2&adults=2&min_price=200&max_price=100", http.StatusBadRequest},
+		{"StarsOutOfRange", "?city=abc&checkin=2025-01-01&nights=2&adults=2&stars=4,7", http.StatusBadRequest},
+		{"InvalidSort", "?city=abc&checkin=2025-01-01&nights=2&adults=2&sort=cheapest", http.StatusBadRequest},
+		{"LimitTooLarge", "?city=abc&checkin=2025-01-01&nights=2&adults=2&limit=1000", http.StatusBadRequest},
+		{"CursorWithoutLimit", "?city=abc&checkin=2025-01-01&nights=2&adults=2&cursor=abc", http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cache := &mockCache{}
 			agg := &mockAggregator{}
-			rl := &mockRateLimiter{allowFunc: func(ip string) bool { return true }}
 			metrics := obs.NewMetrics(prometheus.NewRegistry())
-			h := ht.NewHandler(agg, cache, rl, metrics)
+			h := ht.NewHandler(agg, cache, metrics)
 
 			req := httptest.NewRequest("GET", "/search"+tt.query, nil)
 			req.RemoteAddr = "1.2.3.4:1234"
@@ -111,13 +104,20 @@ func TestHandler_Search_ValidationFailures(t *testing.T) {
 	}
 }
 
-func TestHandler_Search_RateLimit(t *testing.T) {
-	cache := &mockCache{}
-	agg := &mockAggregator{}
-	rl := &mockRateLimiter{allowFunc: func(ip string) bool { return false }}
+func TestHandler_Search_AggregatorError(t *testing.T) {
+	cache := &mockCache{
+		getOrComputeFunc: func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error) {
+			return fn(ctx)
+		},
+	}
+	agg := &mockAggregator{
+		searchFunc: func(ctx context.Context, req *models.SearchRequest) (search.AggregatedResult, error) {
+			return search.AggregatedResult{}, errors.New("aggregator failed")
+		},
+	}
 	metrics := obs.NewMetrics(prometheus.NewRegistry())
 
-	h := ht.NewHandler(agg, cache, rl, metrics)
+	h := ht.NewHandler(agg, cache, metrics)
 
 	req := httptest.NewRequest("GET", "/search?city=abc&checkin=2025-01-01&nights=2&adults=2", nil)
 	req.RemoteAddr = "1.2.3.4:1234"
@@ -125,13 +125,12 @@ func TestHandler_Search_RateLimit(t *testing.T) {
 
 	h.Search(w, req)
 	resp := w.Result()
-
-	if resp.StatusCode != http.StatusTooManyRequests {
-		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", resp.StatusCode)
 	}
 }
 
-func TestHandler_Search_AggregatorError(t *testing.T) {
+func TestHandler_Search_AllProvidersFailed(t *testing.T) {
 	cache := &mockCache{
 		getOrComputeFunc: func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error) {
 			return fn(ctx)
@@ -139,22 +138,16 @@ func TestHandler_Search_AggregatorError(t *testing.T) {
 	}
 	agg := &mockAggregator{
 		searchFunc: func(ctx context.Context, req *models.SearchRequest) (search.AggregatedResult, error) {
-			return search.AggregatedResult{}, errors.New("aggregator failed")
+			return search.AggregatedResult{}, fmt.Errorf("%w: 2 failed, 0 skipped", search.ErrAllProvidersFailed)
 		},
 	}
-	rl := &mockRateLimiter{allowFunc: func(ip string) bool { return true }}
-	metrics := obs.NewMetrics(prometheus.NewRegistry())
-
-	h := ht.NewHandler(agg, cache, rl, metrics)
+	h := ht.NewHandler(agg, cache, obs.NewMetrics(prometheus.NewRegistry()))
 
 	req := httptest.NewRequest("GET", "/search?city=abc&checkin=2025-01-01&nights=2&adults=2", nil)
-	req.RemoteAddr = "1.2.3.4:1234"
 	w := httptest.NewRecorder()
-
 	h.Search(w, req)
-	resp := w.Result()
-	if resp.StatusCode != http.StatusInternalServerError {
-		t.Fatalf("expected 500, got %d", resp.StatusCode)
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d", w.Code)
 	}
 }
 
@@ -165,22 +158,15 @@ func TestHandler_Search_CacheHit(t *testing.T) {
 			called = true
 			return search.AggregatedResult{
 				Hotels: []search.Hotel{{HotelID: "H1", Name: "A", Price: 50, Nights: 1}},
-				Stats: struct {
-					ProvidersTotal     int    "json:\"providers_total\""
-					ProvidersSucceeded int    "json:\"providers_succeeded\""
-					ProvidersFailed    int    "json:\"providers_failed\""
-					Cache              string "json:\"cache\""
-					DurationMs         int64  "json:\"duration_ms\""
-				}{ProvidersTotal: 1, ProvidersSucceeded: 1, ProvidersFailed: 0, Cache: "hit"},
+				Stats:  search.SearchStats{ProvidersTotal: 1, ProvidersSucceeded: 1, ProvidersFailed: 0, Cache: "hit", CacheAgeMs: 12500, CacheTTLMs: 17500},
 			}, nil
 		},
 	}
 
 	agg := &mockAggregator{}
-	rl := &mockRateLimiter{allowFunc: func(ip string) bool { return true }}
 	metrics := obs.NewMetrics(prometheus.NewRegistry())
 
-	h := ht.NewHandler(agg, cache, rl, metrics)
+	h := ht.NewHandler(agg, cache, metrics)
 
 	req := httptest.NewRequest("GET", "/search?city=abc&checkin=2025-01-01&nights=1&adults=1", nil)
 	req.RemoteAddr = "1.2.3.4:1234"
@@ -220,4 +206,65 @@ func TestHandler_Search_CacheHit(t *testing.T) {
 	if !called {
 		t.Fatal("expected cache GetOrCompute to be called")
 	}
+	if got := resp.Header.Get("Age"); got != "12" {
+		t.Errorf("expected Age 12, got %q", got)
+	}
+	if got := resp.Header.Get("Cache-Status"); got != "hotel-aggregator; hit; ttl=17" {
+		t.Errorf("unexpected Cache-Status %q", got)
+	}
+	stats := out["stats"].(map[string]any)
+	if stats["cache"] != "hit" || stats["cache_age_ms"] != 12500.0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestHandler_Search_Pagination(t *testing.T) {
+	cache := &mockCache{
+		getOrComputeFunc: func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error) {
+			return search.AggregatedResult{Hotels: []search.Hotel{
+				{HotelID: "H1", Name: "A", Price: 50},
+				{HotelID: "H2", Name: "B", Price: 60},
+				{HotelID: "H3", Name: "C", Price: 70},
+			}}, nil
+		},
+	}
+	h := ht.NewHandler(&mockAggregator{}, cache, obs.NewMetrics(prometheus.NewRegistry()))
+
+	get := func(query string) (int, map[string]any) {
+		req := httptest.NewRequest("GET", "/search?city=abc&checkin=2025-01-01&nights=1&adults=1"+query, nil)
+		req.RemoteAddr = "1.2.3.4:1234"
+		w := httptest.NewRecorder()
+		h.Search(w, req)
+		var out map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	code, out := get("&sort=-price&limit=2")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	hotels := out["hotels"].([]any)
+	if len(hotels) != 2 || hotels[0].(map[string]any)["hotel_id"] != "H3" {
+		t.Fatalf("unexpected first page %+v", hotels)
+	}
+	page := out["page"].(map[string]any)
+	cursor, _ := page["next_cursor"].(string)
+	if cursor == "" || page["total"] != float64(3) {
+		t.Fatalf("unexpected page info %+v", page)
+	}
+
+	code, out = get("&sort=-price&limit=2&cursor=" + cursor)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	hotels = out["hotels"].([]any)
+	if len(hotels) != 1 || hotels[0].(map[string]any)["hotel_id"] != "H1" {
+		t.Fatalf("unexpected second page %+v", hotels)
+	}
+
+	// the cursor belongs to the -price ordering
+	if code, _ = get("&sort=name&limit=2&cursor=" + cursor); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a cursor from another query, got %d", code)
+	}
 }
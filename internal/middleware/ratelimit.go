@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	handlers "github.com/example/mini-hotel-aggregator/internal/http"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/search"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// RateLimitPolicy configures RateLimit for one route.
+type RateLimitPolicy struct {
+	// Route names the route in hotel_ratelimit_drops_total and scopes the
+	// limiter state, so routes sharing a limiter are limited separately.
+	Route   string
+	Limiter search.RateLimiter
+	// Plan applies to clients without an API key, limited per IP.
+	Plan search.Plan
+	// APIKeys, when set, identifies clients by their X-API-Key header and
+	// limits them under their own plan; unknown keys are limited under Plan
+	// per IP, then rejected.
+	APIKeys *search.APIKeyStore
+}
+
+// RateLimit limits requests before they reach the handler, so malformed
+// requests are counted too. Every response carries RateLimit-* headers for
+// the limit closest to exhaustion; denied requests get a 429 with
+// Retry-After.
+func RateLimit(p RateLimitPolicy, m *obs.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id, plan, ok := identify(p, r)
+			d := p.Limiter.Allow(p.Route+"|"+id, plan)
+			setRateLimitHeaders(w, d)
+			if !d.Allowed {
+				m.IncRateLimitDrops(p.Route)
+				handlers.TooManyRequests(w, "rate limit exceeded", requestMeta(r))
+				return
+			}
+			if !ok {
+				handlers.Unauthorized(w, "invalid API key", requestMeta(r))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// identify returns the rate limit identity and plan of the caller, and false
+// when it presented an unknown API key. Such callers are limited like
+// anonymous ones, so guessing keys costs the same allowance as any request.
+func identify(p RateLimitPolicy, r *http.Request) (string, search.Plan, bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" && p.APIKeys != nil {
+		if c, ok := p.APIKeys.Lookup(key); ok {
+			return "key:" + c.ID, c.Plan, true
+		}
+		return "ip:" + ipFromRequest(r), p.Plan, false
+	}
+	return "ip:" + ipFromRequest(r), p.Plan, true
+}
+
+func ipFromRequest(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func requestMeta(r *http.Request) map[string]string {
+	if id := chimw.GetReqID(r.Context()); id != "" {
+		return map[string]string{"request_id": id}
+	}
+	return nil
+}
+
+// setRateLimitHeaders reports the decision as RateLimit-* headers, in
+// seconds, and tells denied clients when to retry. Denials without a limit,
+// such as a full limiter or an unreachable store, still get Retry-After.
+func setRateLimitHeaders(w http.ResponseWriter, d search.Decision) {
+	hdr := w.Header()
+	if !d.Allowed {
+		hdr.Set("Retry-After", strconv.FormatInt(max(1, ceilSeconds(d.RetryAfter)), 10))
+	}
+	if d.Limit == 0 {
+		return
+	}
+	hdr.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	hdr.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	hdr.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(d.Reset), 10))
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,179 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mid "github.com/example/mini-hotel-aggregator/internal/middleware"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/search"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type mockRateLimiter struct {
+	allowFunc func(id string, plan search.Plan) search.Decision
+}
+
+func (m *mockRateLimiter) Allow(id string, plan search.Plan) search.Decision {
+	return m.allowFunc(id, plan)
+}
+
+// serve runs one request through RateLimit in front of a handler answering
+// 200, and reports whether that handler was reached.
+func serve(t *testing.T, p mid.RateLimitPolicy, m *obs.Metrics, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	mid.RateLimit(p, m)(next).ServeHTTP(w, req)
+	return w, reached
+}
+
+func TestRateLimit_RejectsBeforeHandler(t *testing.T) {
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	rl := &mockRateLimiter{allowFunc: func(id string, plan search.Plan) search.Decision { return search.Decision{} }}
+	p := mid.RateLimitPolicy{Route: "search", Limiter: rl, Plan: search.DefaultPlan}
+
+	// malformed requests are throttled too
+	req := httptest.NewRequest("GET", "/search?city=x", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	w, reached := serve(t, p, m, req)
+
+	if w.Code != http.StatusTooManyRequests || reached {
+		t.Fatalf("expected 429 without reaching the handler, got %d (reached %v)", w.Code, reached)
+	}
+	if got := testutil.ToFloat64(m.RateLimitDropsTotal.WithLabelValues("search")); got != 1 {
+		t.Fatalf("expected 1 drop for the search route, got %v", got)
+	}
+}
+
+func TestRateLimit_Headers(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision search.Decision
+		status   int
+		want     map[string]string
+	}{
+		{
+			name:     "allowed",
+			decision: search.Decision{Allowed: true, Limit: 10, Remaining: 7, Reset: 17500 * time.Millisecond},
+			status:   http.StatusOK,
+			want:     map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "7", "RateLimit-Reset": "18", "Retry-After": ""},
+		},
+		{
+			name:     "denied",
+			decision: search.Decision{Limit: 10, Reset: time.Minute, RetryAfter: 5500 * time.Millisecond},
+			status:   http.StatusTooManyRequests,
+			want:     map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "0", "RateLimit-Reset": "60", "Retry-After": "6"},
+		},
+		{
+			// a limiter at its key cap under the reject policy
+			name:     "overflow rejected",
+			decision: search.Decision{RetryAfter: time.Second},
+			status:   http.StatusTooManyRequests,
+			want:     map[string]string{"RateLimit-Limit": "", "RateLimit-Remaining": "", "Retry-After": "1"},
+		},
+		{
+			// a shared limiter with an unreachable store under StoreFailureClosed
+			name:     "store failure closed",
+			decision: search.Decision{RetryAfter: 5 * time.Second},
+			status:   http.StatusTooManyRequests,
+			want:     map[string]string{"RateLimit-Limit": "", "RateLimit-Remaining": "", "Retry-After": "5"},
+		},
+		{
+			name:     "unlimited",
+			decision: search.Decision{Allowed: true},
+			status:   http.StatusOK,
+			want:     map[string]string{"RateLimit-Limit": "", "RateLimit-Remaining": "", "Retry-After": ""},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rl := &mockRateLimiter{allowFunc: func(id string, plan search.Plan) search.Decision { return tc.decision }}
+			p := mid.RateLimitPolicy{Route: "search", Limiter: rl, Plan: search.DefaultPlan}
+			req := httptest.NewRequest("GET", "/search", nil)
+			w, _ := serve(t, p, obs.NewMetrics(prometheus.NewRegistry()), req)
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, w.Code)
+			}
+			for k, v := range tc.want {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("expected %s %q, got %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimit_UnknownKeyLimitedFirst(t *testing.T) {
+	store, err := search.LoadAPIKeyStore("../../config/api_keys.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	rl := &mockRateLimiter{allowFunc: func(id string, plan search.Plan) search.Decision {
+		return search.Decision{Limit: 10, RetryAfter: time.Second}
+	}}
+	p := mid.RateLimitPolicy{Route: "search", Limiter: rl, Plan: search.DefaultPlan, APIKeys: store}
+
+	// a client guessing keys runs out of allowance like any other
+	req := httptest.NewRequest("GET", "/search", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+	req.Header.Set("X-API-Key", "nope")
+	w, _ := serve(t, p, m, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the ip is limited, got %d", w.Code)
+	}
+	if got := testutil.ToFloat64(m.RateLimitDropsTotal.WithLabelValues("search")); got != 1 {
+		t.Fatalf("expected 1 drop for the search route, got %v", got)
+	}
+}
+
+func TestRateLimit_Identity(t *testing.T) {
+	store, err := search.LoadAPIKeyStore("../../config/api_keys.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotID string
+	var gotPlan search.Plan
+	rl := &mockRateLimiter{allowFunc: func(id string, plan search.Plan) search.Decision {
+		gotID, gotPlan = id, plan
+		return search.Decision{Allowed: true}
+	}}
+
+	tests := []struct {
+		name     string
+		policy   mid.RateLimitPolicy
+		key      string
+		status   int
+		wantID   string
+		wantPlan string
+	}{
+		{"known key uses its plan", mid.RateLimitPolicy{Route: "search", Plan: search.DefaultPlan, APIKeys: store}, "demo-partner-key", http.StatusOK, "search|key:demo-partner", "partner"},
+		{"no key falls back to ip", mid.RateLimitPolicy{Route: "search", Plan: search.DefaultPlan, APIKeys: store}, "", http.StatusOK, "search|ip:1.2.3.4", "anonymous"},
+		{"unknown key is rejected", mid.RateLimitPolicy{Route: "search", Plan: search.DefaultPlan, APIKeys: store}, "nope", http.StatusUnauthorized, "search|ip:1.2.3.4", "anonymous"},
+		{"routes are limited separately", mid.RateLimitPolicy{Route: "admin", Plan: search.Plan{Name: "admin"}}, "", http.StatusOK, "admin|ip:1.2.3.4", "admin"},
+		{"keys ignored without a store", mid.RateLimitPolicy{Route: "admin", Plan: search.Plan{Name: "admin"}}, "nope", http.StatusOK, "admin|ip:1.2.3.4", "admin"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotID, gotPlan = "", search.Plan{}
+			tc.policy.Limiter = rl
+			req := httptest.NewRequest("GET", "/search", nil)
+			req.RemoteAddr = "1.2.3.4:1234"
+			if tc.key != "" {
+				req.Header.Set("X-API-Key", tc.key)
+			}
+			w, _ := serve(t, tc.policy, obs.NewMetrics(prometheus.NewRegistry()), req)
+			if w.Code != tc.status || gotID != tc.wantID || gotPlan.Name != tc.wantPlan {
+				t.Fatalf("expected %d for %q on plan %q, got %d for %q on plan %q", tc.status, tc.wantID, tc.wantPlan, w.Code, gotID, gotPlan.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CacheKeyVersion is part of every cache key. Bump it whenever the meaning of
+// a cached result changes so entries written by older builds are never read.
+const CacheKeyVersion = 1
+
+// cacheKeyFields lists every request field that changes the aggregated
+// result. Filters and Page are applied on top of the cached result and stay
+// out of the key. Field order is fixed by the struct, so the encoding is
+// stable.
+type cacheKeyFields struct {
+	City     string `json:"city"`
+	Checkin  string `json:"checkin"`
+	Nights   int    `json:"nights"`
+	Adults   int    `json:"adults"`
+	Currency string `json:"currency"`
+}
+
+// CacheKey returns the canonical cache key of a validated request:
+// "v<version>|<city>|<sha256 of the result-affecting fields>". The readable
+// city part lets a whole city be purged, see CityKeyPrefix.
+func (r *SearchRequest) CacheKey() string {
+	b, _ := json.Marshal(cacheKeyFields{
+		City:     r.City,
+		Checkin:  r.Checkin,
+		Nights:   r.Nights,
+		Adults:   r.Adults,
+		Currency: r.Currency,
+	})
+	sum := sha256.Sum256(b)
+	return CityKeyPrefix(r.City) + hex.EncodeToString(sum[:])
+}
+
+// CityKeyPrefix is the prefix shared by the cache keys of every search in
+// city. The city is escaped so it cannot contain the separator.
+func CityKeyPrefix(city string) string {
+	return fmt.Sprintf("v%d|%s|", CacheKeyVersion, url.QueryEscape(strings.ToLower(strings.TrimSpace(city))))
+}
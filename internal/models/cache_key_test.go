@@ -0,0 +1,45 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCacheKey(t *testing.T) {
+	base := SearchRequest{City: "paris", Checkin: "2025-01-01", Nights: 2, Adults: 2}
+	key := base.CacheKey()
+
+	if !strings.HasPrefix(key, "v1|paris|") || !strings.HasPrefix(key, CityKeyPrefix(" Paris ")) {
+		t.Fatalf("expected key under the versioned city prefix, got %q", key)
+	}
+	if again := base.CacheKey(); again != key {
+		t.Fatalf("expected a stable key, got %q then %q", key, again)
+	}
+
+	presentation := base
+	presentation.Filters = SearchFilters{MinPrice: 50, Stars: []int{4}}
+	presentation.Page = PageRequest{Sort: SortName, Limit: 10, Cursor: "abc"}
+	if got := presentation.CacheKey(); got != key {
+		t.Fatalf("expected filters and page to be ignored, got %q want %q", got, key)
+	}
+
+	for name, mutate := range map[string]func(r *SearchRequest){
+		"city":     func(r *SearchRequest) { r.City = "rome" },
+		"checkin":  func(r *SearchRequest) { r.Checkin = "2025-01-02" },
+		"nights":   func(r *SearchRequest) { r.Nights = 3 },
+		"adults":   func(r *SearchRequest) { r.Adults = 1 },
+		"currency": func(r *SearchRequest) { r.Currency = "USD" },
+	} {
+		r := base
+		mutate(&r)
+		if r.CacheKey() == key {
+			t.Errorf("expected %s to change the key", name)
+		}
+	}
+}
+
+func TestCityKeyPrefix_EscapesSeparator(t *testing.T) {
+	if got := CityKeyPrefix("a|b"); got != "v1|a%7Cb|" {
+		t.Fatalf("expected escaped city, got %q", got)
+	}
+}
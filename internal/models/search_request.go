@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -14,10 +15,99 @@ type SearchRequest struct {
 	Checkin string
 	Nights  int
 	Adults  int
+	// Currency is the display currency, empty means the service default.
+	Currency string
+	// Filters narrow the aggregated result; they are applied on top of the
+	// cached result and are not part of the cache key.
+	Filters SearchFilters
+	// Page controls ordering and paging of the response, also outside the
+	// cache key.
+	Page PageRequest
 }
 
+// Sort orders accepted by /search.
+const (
+	SortPrice     = "price"
+	SortPriceDesc = "-price"
+	SortName      = "name"
+	SortRating    = "rating"
+	SortRelevance = "relevance"
+)
+
+const MaxPageLimit = 100
+
+// PageRequest selects the order and the slice of results to return. A zero
+// Limit returns every result.
+type PageRequest struct {
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+func ParsePage(sort, limit, cursor string) (PageRequest, error) {
+	p := PageRequest{Sort: strings.ToLower(strings.TrimSpace(sort)), Cursor: cursor}
+	if p.Sort == "" {
+		p.Sort = SortPrice
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("invalid limit")
+		}
+		p.Limit = n
+	}
+	return p, nil
+}
+
+// SearchFilters are optional result filters. Zero values mean "no filter".
+type SearchFilters struct {
+	MinPrice     float64
+	MaxPrice     float64
+	Stars        []int
+	Amenities    []string
+	NameContains string
+}
 
-func NewSearchRequest(city, checkin, nights, adults string) (*SearchRequest, error) {
+// ParseFilters parses the raw filter query values. stars and amenities are
+// comma separated lists.
+func ParseFilters(minPrice, maxPrice, stars, amenities, nameContains string) (SearchFilters, error) {
+	var f SearchFilters
+	var err error
+	if minPrice != "" {
+		if f.MinPrice, err = strconv.ParseFloat(minPrice, 64); err != nil {
+			return f, fmt.Errorf("invalid min_price")
+		}
+	}
+	if maxPrice != "" {
+		if f.MaxPrice, err = strconv.ParseFloat(maxPrice, 64); err != nil {
+			return f, fmt.Errorf("invalid max_price")
+		}
+	}
+	for _, s := range splitList(stars) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return f, fmt.Errorf("invalid stars")
+		}
+		f.Stars = append(f.Stars, n)
+	}
+	for _, a := range splitList(amenities) {
+		f.Amenities = append(f.Amenities, strings.ToLower(a))
+	}
+	f.NameContains = strings.TrimSpace(nameContains)
+	return f, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func NewSearchRequest(city, checkin, nights, adults, currency string) (*SearchRequest, error) {
 	if city == "" || checkin == "" || nights == "" || adults == "" {
 		return nil, fmt.Errorf("missing required params")
 	}
@@ -30,10 +120,11 @@ func NewSearchRequest(city, checkin, nights, adults string) (*SearchRequest, err
 		return nil, fmt.Errorf("invalid adults")
 	}
 	return &SearchRequest{
-		City:    city,
-		Checkin: checkin,
-		Nights:  nightsInt,
-		Adults:  adultsInt,
+		City:     city,
+		Checkin:  checkin,
+		Nights:   nightsInt,
+		Adults:   adultsInt,
+		Currency: currency,
 	}, nil
 }
 
@@ -44,7 +135,7 @@ func (r *SearchRequest) Validate() error {
 	if err != nil {
 		errs = append(errs, err.Error())
 	} else {
-		r.City = city 
+		r.City = city
 	}
 
 	_, err = validator.ValidateDate(r.Checkin)
@@ -52,6 +143,13 @@ func (r *SearchRequest) Validate() error {
 		errs = append(errs, err.Error())
 	}
 
+	currency, err := validator.ValidateCurrency(r.Currency)
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		r.Currency = currency
+	}
+
 	if r.Nights <= 0 || r.Nights > 365 {
 		errs = append(errs, "invalid or excessive nights")
 	}
@@ -59,8 +157,53 @@ func (r *SearchRequest) Validate() error {
 		errs = append(errs, "invalid or excessive adults")
 	}
 
+	errs = append(errs, r.Filters.validate()...)
+	errs = append(errs, r.Page.validate()...)
+
 	if len(errs) > 0 {
 		return errors.New(strings.Join(errs, ", "))
 	}
 	return nil
 }
+
+func (f SearchFilters) validate() []string {
+	var errs []string
+	if f.MinPrice < 0 || math.IsNaN(f.MinPrice) || math.IsInf(f.MinPrice, 0) {
+		errs = append(errs, "invalid min_price")
+	}
+	if f.MaxPrice < 0 || math.IsNaN(f.MaxPrice) || math.IsInf(f.MaxPrice, 0) {
+		errs = append(errs, "invalid max_price")
+	}
+	if f.MaxPrice > 0 && f.MinPrice > f.MaxPrice {
+		errs = append(errs, "min_price greater than max_price")
+	}
+	for _, s := range f.Stars {
+		if s < 1 || s > 5 {
+			errs = append(errs, "stars must be between 1 and 5")
+			break
+		}
+	}
+	if len(f.Amenities) > 20 {
+		errs = append(errs, "too many amenities")
+	}
+	if len(f.NameContains) > 100 {
+		errs = append(errs, "name_contains too long")
+	}
+	return errs
+}
+
+func (p PageRequest) validate() []string {
+	var errs []string
+	switch p.Sort {
+	case "", SortPrice, SortPriceDesc, SortName, SortRating, SortRelevance:
+	default:
+		errs = append(errs, "invalid sort")
+	}
+	if p.Limit < 0 || p.Limit > MaxPageLimit {
+		errs = append(errs, fmt.Sprintf("limit must be between 1 and %d", MaxPageLimit))
+	}
+	if p.Cursor != "" && p.Limit == 0 {
+		errs = append(errs, "cursor requires limit")
+	}
+	return errs
+}
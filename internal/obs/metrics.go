@@ -2,21 +2,61 @@ package obs
 
 import (
 	"net/http"
+	"sort"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// providerLatencyBuckets grow exponentially past the aggregator's 2s
+// timeout, so the tail of slow suppliers is not clipped to the last bound.
+var providerLatencyBuckets = prometheus.ExponentialBucketsRange(5, 5000, 16)
+
 type Metrics struct {
 	RequestsTotal       prometheus.Counter
 	CacheHitsTotal      prometheus.Counter
-	RateLimitDropsTotal prometheus.Counter
+	RateLimitDropsTotal *prometheus.CounterVec
+	RateLimitKeys       *prometheus.GaugeVec
+	// RateLimitStoreErrors counts failed calls to a shared rate limit store.
+	RateLimitStoreErrors prometheus.Counter
+
+	CacheEntries   prometheus.Gauge
+	CacheBytes     prometheus.Gauge
+	CacheEvictions *prometheus.CounterVec
+	// CacheBackendErrors counts failed calls to a shared cache backend.
+	CacheBackendErrors prometheus.Counter
+
+	CacheWarmRuns     prometheus.Counter
+	CacheWarmSearches *prometheus.CounterVec
+	CacheWarmHits     prometheus.Counter
+
+	ProviderErrors       *prometheus.CounterVec
+	ProviderRetries      *prometheus.CounterVec
+	ProviderCacheHits    *prometheus.CounterVec
+	ProviderHedges       *prometheus.CounterVec
+	ProviderHedgeWins    *prometheus.CounterVec
+	ProviderLatency      *prometheus.HistogramVec
+	ProviderBreakerState *prometheus.GaugeVec
+	HTTPRequestDuration  *prometheus.HistogramVec
+	HTTPRequestsTotal    *prometheus.CounterVec
+	Registry             *prometheus.Registry
 
-	ProviderErrors      *prometheus.CounterVec
-	ProviderLatency     *prometheus.HistogramVec
-	HTTPRequestDuration *prometheus.HistogramVec
-	HTTPRequestsTotal   *prometheus.CounterVec
-	Registry            *prometheus.Registry
+	// ProviderOffersDropped counts offers whose price could not be
+	// converted to the display currency.
+	ProviderOffersDropped *prometheus.CounterVec
+
+	// latency mirrors ProviderLatency per provider, so hedging reads a
+	// quantile without collecting the whole vector on every call.
+	latencyMu sync.Mutex
+	latency   map[string]*latencyCounts
+}
+
+// latencyCounts holds a provider's observations per providerLatencyBuckets
+// bucket, the last one being +Inf.
+type latencyCounts struct {
+	buckets []uint64
+	total   uint64
 }
 
 // Create Prometheus collectors and register them
@@ -30,20 +70,92 @@ func NewMetrics(p *prometheus.Registry) *Metrics {
 			Name: "hotel_cache_hits_total",
 			Help: "Number of cache hits for search results",
 		}),
+		CacheEntries: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hotel_cache_entries",
+			Help: "Number of keys held by the search cache",
+		}),
+		CacheBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hotel_cache_bytes",
+			Help: "Estimated memory held by cached search results",
+		}),
+		CacheEvictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hotel_cache_evictions_total",
+			Help: "Cache entries removed, by reason (expired, capacity)",
+		}, []string{"reason"},
+		),
+		CacheBackendErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "hotel_cache_backend_errors_total",
+			Help: "Failed calls to the shared cache backend",
+		}),
+		CacheWarmRuns: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "hotel_cache_warm_runs_total",
+			Help: "Completed cache warming runs",
+		}),
+		CacheWarmSearches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hotel_cache_warm_searches_total",
+			Help: "Searches run by the cache warmer, by result (ok, error)",
+		}, []string{"result"},
+		),
+		CacheWarmHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "hotel_cache_warm_hits_total",
+			Help: "User requests served from entries stored by the cache warmer",
+		}),
 		ProviderErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "provider_errors_total",
 			Help: "Errors returned by each provider",
 		}, []string{"provider"},
 		),
-		RateLimitDropsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+		ProviderRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "provider_retries_total",
+			Help: "Retry attempts made against each provider",
+		}, []string{"provider"},
+		),
+		ProviderCacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "provider_cache_hits_total",
+			Help: "Searches that reused a provider's cached answer instead of calling it",
+		}, []string{"provider"},
+		),
+		ProviderOffersDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "provider_offers_dropped_total",
+			Help: "Offers dropped because their price could not be converted to the display currency",
+		}, []string{"provider"},
+		),
+		ProviderHedges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "provider_hedged_requests_total",
+			Help: "Hedge calls fired because a provider exceeded its latency quantile",
+		}, []string{"provider"},
+		),
+		ProviderHedgeWins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "provider_hedge_wins_total",
+			Help: "Hedge calls that answered before the original call",
+		}, []string{"provider"},
+		),
+		RateLimitDropsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "hotel_ratelimit_drops_total",
 			Help: "Requests dropped due to rate limiting",
+		}, []string{"route"},
+		),
+		RateLimitKeys: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "hotel_ratelimit_tracked_keys",
+			Help: "Clients currently tracked by each rate limiter",
+		}, []string{"limiter"},
+		),
+		RateLimitStoreErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "hotel_ratelimit_store_errors_total",
+			Help: "Failed calls to the shared rate limit store",
 		}),
 		ProviderLatency: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "provider_latency_ms",
 				Help:    "Latency between aggregator and provider",
-				Buckets: prometheus.LinearBuckets(5, 20, 15),
+				Buckets: providerLatencyBuckets,
+			},
+			[]string{"provider"},
+		),
+		ProviderBreakerState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "provider_circuit_breaker_state",
+				Help: "Circuit breaker state per provider (0=closed, 1=half-open, 2=open)",
 			},
 			[]string{"provider"},
 		),
@@ -63,15 +175,31 @@ func NewMetrics(p *prometheus.Registry) *Metrics {
 			[]string{"method", "path", "status"},
 		),
 		Registry: p,
+		latency:  make(map[string]*latencyCounts),
 	}
 
 	// Register metrics with Prometheus
 	p.MustRegister(
 		m.RequestsTotal,
 		m.CacheHitsTotal,
+		m.CacheEntries,
+		m.CacheBytes,
+		m.CacheEvictions,
+		m.CacheBackendErrors,
+		m.CacheWarmRuns,
+		m.CacheWarmSearches,
+		m.CacheWarmHits,
 		m.ProviderErrors,
+		m.ProviderRetries,
+		m.ProviderCacheHits,
+		m.ProviderOffersDropped,
+		m.ProviderHedges,
+		m.ProviderHedgeWins,
 		m.RateLimitDropsTotal,
+		m.RateLimitKeys,
+		m.RateLimitStoreErrors,
 		m.ProviderLatency,
+		m.ProviderBreakerState,
 		m.HTTPRequestDuration,
 		m.HTTPRequestsTotal,
 	)
@@ -82,16 +210,109 @@ func NewMetrics(p *prometheus.Registry) *Metrics {
 func (m *Metrics) IncRequests()  { m.RequestsTotal.Inc() }
 func (m *Metrics) IncCacheHits() { m.CacheHitsTotal.Inc() }
 
-func (m *Metrics) IncRateLimitDrops() { m.RateLimitDropsTotal.Inc() }
+func (m *Metrics) SetCacheSize(entries int, bytes int64) {
+	m.CacheEntries.Set(float64(entries))
+	m.CacheBytes.Set(float64(bytes))
+}
+
+func (m *Metrics) IncCacheEvictions(reason string) {
+	m.CacheEvictions.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) IncCacheBackendErrors() { m.CacheBackendErrors.Inc() }
+
+func (m *Metrics) IncCacheWarmRun(ok, failed int) {
+	m.CacheWarmRuns.Inc()
+	m.CacheWarmSearches.WithLabelValues("ok").Add(float64(ok))
+	m.CacheWarmSearches.WithLabelValues("error").Add(float64(failed))
+}
+
+func (m *Metrics) IncCacheWarmHits() { m.CacheWarmHits.Inc() }
+
+func (m *Metrics) SetRateLimitKeys(limiter string, n int) {
+	m.RateLimitKeys.WithLabelValues(limiter).Set(float64(n))
+}
+
+func (m *Metrics) IncRateLimitStoreErrors() { m.RateLimitStoreErrors.Inc() }
+
+func (m *Metrics) IncRateLimitDrops(route string) {
+	m.RateLimitDropsTotal.WithLabelValues(route).Inc()
+}
+
+func (m *Metrics) ObserveProviderLatency(provider string, ms float64) {
+	m.ProviderLatency.WithLabelValues(provider).Observe(ms)
+
+	m.latencyMu.Lock()
+	defer m.latencyMu.Unlock()
+	c, ok := m.latency[provider]
+	if !ok {
+		c = &latencyCounts{buckets: make([]uint64, len(providerLatencyBuckets)+1)}
+		m.latency[provider] = c
+	}
+	// buckets are inclusive upper bounds, as in the histogram
+	c.buckets[sort.SearchFloat64s(providerLatencyBuckets, ms)]++
+	c.total++
+}
+
+// ProviderLatencyQuantile estimates quantile q (0..1) of a provider's latency
+// in milliseconds from the provider_latency_ms buckets, interpolating
+// linearly inside the matching bucket. It also returns the sample count so
+// callers can ignore estimates built on too little data.
+func (m *Metrics) ProviderLatencyQuantile(provider string, q float64) (float64, uint64) {
+	m.latencyMu.Lock()
+	defer m.latencyMu.Unlock()
+	c, ok := m.latency[provider]
+	if !ok || c.total == 0 {
+		return 0, 0
+	}
+	total := c.total
 
-func (m *Metrics) ObserveProviderLatency(provider string, seconds float64) {
-	m.ProviderLatency.WithLabelValues(provider).Observe(seconds)
+	rank := q * float64(total)
+	prevBound, prevCount := 0.0, uint64(0)
+	for i, bound := range providerLatencyBuckets {
+		count := prevCount + c.buckets[i]
+		if float64(count) >= rank {
+			inBucket := count - prevCount
+			if inBucket == 0 {
+				return bound, total
+			}
+			frac := (rank - float64(prevCount)) / float64(inBucket)
+			return prevBound + frac*(bound-prevBound), total
+		}
+		prevBound, prevCount = bound, count
+	}
+	// quantile falls in the +Inf bucket, the best we can say is the last bound
+	return prevBound, total
 }
 
 func (m *Metrics) IncProviderFailure(provider string) {
 	m.ProviderErrors.WithLabelValues(provider).Inc()
 }
 
+func (m *Metrics) IncProviderRetry(provider string) {
+	m.ProviderRetries.WithLabelValues(provider).Inc()
+}
+
+func (m *Metrics) IncProviderCacheHit(provider string) {
+	m.ProviderCacheHits.WithLabelValues(provider).Inc()
+}
+
+func (m *Metrics) IncProviderOfferDropped(provider string) {
+	m.ProviderOffersDropped.WithLabelValues(provider).Inc()
+}
+
+func (m *Metrics) IncProviderHedge(provider string) {
+	m.ProviderHedges.WithLabelValues(provider).Inc()
+}
+
+func (m *Metrics) IncProviderHedgeWin(provider string) {
+	m.ProviderHedgeWins.WithLabelValues(provider).Inc()
+}
+
+func (m *Metrics) SetProviderBreakerState(provider string, state float64) {
+	m.ProviderBreakerState.WithLabelValues(provider).Set(state)
+}
+
 func (m *Metrics) ObserveHTTPRequestDuration(method string, path string, status string, seconds float64) {
 	m.HTTPRequestDuration.WithLabelValues(method, path, status).Observe(seconds)
 }
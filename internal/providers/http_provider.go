@@ -0,0 +1,383 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/search"
+)
+
+// HTTPProviderConfig describes how to talk to a single JSON supplier API.
+//
+// URL, query values, header values and the body template may reference the
+// search request through placeholders: {city}, {checkin}, {checkout},
+// {nights} and {adults}.
+type HTTPProviderConfig struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Query   map[string]string `json:"query"`
+	Headers map[string]string `json:"headers"`
+	// Body is a raw JSON template; string placeholders are JSON-escaped.
+	Body    string          `json:"body"`
+	Mapping ResponseMapping `json:"mapping"`
+	// Currency is the ISO 4217 code of prices whose item has no mapped
+	// currency. Without either, offers are dropped once prices are converted.
+	Currency string `json:"currency"`
+	// MaxBodyBytes caps the response size read from the supplier,
+	// DefaultMaxBodyBytes when unset.
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+}
+
+// DefaultMaxBodyBytes bounds supplier responses when the config sets no cap.
+const DefaultMaxBodyBytes = 10 << 20
+
+// ResponseMapping locates hotel fields in the supplier response using dotted
+// paths (e.g. "data.results" or "rate.total.amount"). Results is resolved
+// from the document root, every other path from each result item.
+type ResponseMapping struct {
+	Results  string `json:"results"`
+	HotelID  string `json:"hotel_id"`
+	Name     string `json:"name"`
+	City     string `json:"city"`
+	Currency string `json:"currency"`
+	Price    string `json:"price"`
+	Nights   string `json:"nights"`
+
+	// optional property details; amenities may be an array or a comma list
+	Stars     string `json:"stars"`
+	Amenities string `json:"amenities"`
+	Rating    string `json:"rating"`
+
+	// rate conditions, both optional
+	Refundable string `json:"refundable"`
+	Board      string `json:"board"`
+}
+
+// HTTPProvider is a search.Provider backed by a configurable HTTP/JSON API.
+type HTTPProvider struct {
+	cfg    HTTPProviderConfig
+	client *http.Client
+}
+
+func NewHTTPProvider(cfg HTTPProviderConfig, client *http.Client) (*HTTPProvider, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("http provider: missing name")
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("http provider %s: missing url", cfg.Name)
+	}
+	if cfg.Mapping.HotelID == "" || cfg.Mapping.Price == "" {
+		return nil, fmt.Errorf("http provider %s: mapping requires hotel_id and price", cfg.Name)
+	}
+	if cfg.Method == "" {
+		cfg.Method = http.MethodGet
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	cfg.Method = strings.ToUpper(cfg.Method)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPProvider{cfg: cfg, client: client}, nil
+}
+
+// LoadHTTPProviderConfigs reads a JSON array of provider configs from path.
+func LoadHTTPProviderConfigs(path string) ([]HTTPProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs []HTTPProviderConfig
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return nil, fmt.Errorf("parse provider config %s: %w", path, err)
+	}
+	return cfgs, nil
+}
+
+func (p *HTTPProvider) Name() string { return p.cfg.Name }
+
+func (p *HTTPProvider) Search(ctx context.Context, req *models.SearchRequest) ([]search.Hotel, error) {
+	httpReq, err := p.buildRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the request carries ctx, so the aggregator deadline aborts the call
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		// transport failures (refused, reset, DNS) are usually transient
+		return nil, search.Retryable(fmt.Errorf("%s: %w", p.cfg.Name, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, &search.ProviderError{
+			Retryable:  retryableStatus(resp.StatusCode),
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("%s: unexpected status %d", p.cfg.Name, resp.StatusCode),
+		}
+	}
+
+	// read one byte past the cap to tell an oversized body from a broken one
+	body := &io.LimitedReader{R: resp.Body, N: p.cfg.MaxBodyBytes + 1}
+	var doc any
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		if body.N <= 0 {
+			return nil, search.Permanent(fmt.Errorf("%s: response exceeds %d bytes", p.cfg.Name, p.cfg.MaxBodyBytes))
+		}
+		return nil, search.Permanent(fmt.Errorf("%s: decode response: %w", p.cfg.Name, err))
+	}
+	return p.mapHotels(doc, req)
+}
+
+func (p *HTTPProvider) buildRequest(ctx context.Context, req *models.SearchRequest) (*http.Request, error) {
+	vars := requestVars(req)
+
+	u, err := url.Parse(expand(p.cfg.URL, vars, url.PathEscape))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid url: %w", p.cfg.Name, err)
+	}
+	if len(p.cfg.Query) > 0 {
+		q := u.Query()
+		for k, v := range p.cfg.Query {
+			q.Set(k, expand(v, vars, nil))
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var body io.Reader
+	if p.cfg.Body != "" {
+		body = strings.NewReader(expand(p.cfg.Body, vars, jsonEscape))
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, p.cfg.Method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: build request: %w", p.cfg.Name, err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range p.cfg.Headers {
+		httpReq.Header.Set(k, expand(v, vars, nil))
+	}
+	return httpReq, nil
+}
+
+func (p *HTTPProvider) mapHotels(doc any, req *models.SearchRequest) ([]search.Hotel, error) {
+	m := p.cfg.Mapping
+	raw, ok := lookupPath(doc, m.Results)
+	if !ok {
+		return nil, search.Permanent(fmt.Errorf("%s: results path %q not found", p.cfg.Name, m.Results))
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, search.Permanent(fmt.Errorf("%s: results path %q is not an array", p.cfg.Name, m.Results))
+	}
+
+	hotels := make([]search.Hotel, 0, len(items))
+	for _, item := range items {
+		price, ok := numberAt(item, m.Price)
+		if !ok {
+			// skip items without a usable price, the aggregator would drop them anyway
+			continue
+		}
+		h := search.Hotel{
+			HotelID:  stringAt(item, m.HotelID),
+			Name:     stringAt(item, m.Name),
+			City:     stringAt(item, m.City),
+			Currency: stringAt(item, m.Currency),
+			Price:    price,
+			Nights:   req.Nights,
+
+			Refundable: boolAt(item, m.Refundable),
+			Board:      stringAt(item, m.Board),
+		}
+		if h.City == "" {
+			h.City = req.City
+		}
+		if h.Currency == "" {
+			h.Currency = p.cfg.Currency
+		}
+		if n, ok := numberAt(item, m.Nights); ok {
+			h.Nights = int(n)
+		}
+		if n, ok := numberAt(item, m.Stars); ok {
+			h.Stars = int(n)
+		}
+		h.Amenities = stringsAt(item, m.Amenities)
+		if r, ok := numberAt(item, m.Rating); ok {
+			h.Rating = r
+		}
+		hotels = append(hotels, h)
+	}
+	return hotels, nil
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+func requestVars(req *models.SearchRequest) map[string]string {
+	vars := map[string]string{
+		"city":    req.City,
+		"checkin": req.Checkin,
+		"nights":  strconv.Itoa(req.Nights),
+		"adults":  strconv.Itoa(req.Adults),
+	}
+	if t, err := time.Parse("2006-01-02", req.Checkin); err == nil {
+		vars["checkout"] = t.AddDate(0, 0, req.Nights).Format("2006-01-02")
+	}
+	return vars
+}
+
+// expand replaces {name} placeholders with values, passing each value through
+// escape first when it is non-nil.
+func expand(tmpl string, vars map[string]string, escape func(string) string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		if escape != nil {
+			v = escape(v)
+		}
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	// strip the surrounding quotes, the template provides them; Trim would
+	// also eat the quote of a value ending in an escaped one
+	return string(b[1 : len(b)-1])
+}
+
+// lookupPath walks a decoded JSON document along a dotted path. Numeric
+// segments index into arrays. An empty path returns the document itself.
+func lookupPath(doc any, path string) (any, bool) {
+	if path == "" {
+		return doc, true
+	}
+	cur := doc
+	for _, seg := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			cur = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func stringAt(doc any, path string) string {
+	if path == "" {
+		return ""
+	}
+	v, ok := lookupPath(doc, path)
+	if !ok || v == nil {
+		return ""
+	}
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return ""
+}
+
+func stringsAt(doc any, path string) []string {
+	if path == "" {
+		return nil
+	}
+	v, ok := lookupPath(doc, path)
+	if !ok {
+		return nil
+	}
+	switch t := v.(type) {
+	case string:
+		return strings.Split(t, ",")
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func boolAt(doc any, path string) bool {
+	if path == "" {
+		return false
+	}
+	v, ok := lookupPath(doc, path)
+	if !ok {
+		return false
+	}
+	switch t := v.(type) {
+	case bool:
+		return t
+	case string:
+		b, _ := strconv.ParseBool(t)
+		return b
+	}
+	return false
+}
+
+// numberAt accepts both JSON numbers and numeric strings, suppliers disagree
+// on how to encode amounts.
+func numberAt(doc any, path string) (float64, bool) {
+	if path == "" {
+		return 0, false
+	}
+	v, ok := lookupPath(doc, path)
+	if !ok {
+		return 0, false
+	}
+	var s string
+	switch t := v.(type) {
+	case json.Number:
+		s = t.String()
+	case string:
+		s = strings.TrimSpace(t)
+	default:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
@@ -0,0 +1,274 @@
+package providers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/providers"
+	"github.com/example/mini-hotel-aggregator/internal/search"
+)
+
+func newSupplier(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPProvider_Search_GetMapping(t *testing.T) {
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/cities/paris/hotels" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("from") != "2025-12-01" || q.Get("to") != "2025-12-03" || q.Get("guests") != "2" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("missing api key header")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":{"results":[
+			{"id":"P1","title":"Le Petit","rate":{"amount":"120.50","currency":"EUR","refundable":true,"board":"BB"}},
+			{"id":"P2","title":"Grand","stars":5,"amenities":["wifi","spa"],"rate":{"amount":210,"currency":"EUR"}},
+			{"id":"P3","title":"No Price","rate":{}}
+		]}}`)
+	})
+
+	p, err := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:    "supplier-a",
+		URL:     srv.URL + "/v1/cities/{city}/hotels",
+		Query:   map[string]string{"from": "{checkin}", "to": "{checkout}", "guests": "{adults}"},
+		Headers: map[string]string{"X-Api-Key": "secret"},
+		Mapping: providers.ResponseMapping{
+			Results:  "data.results",
+			HotelID:  "id",
+			Name:     "title",
+			Currency: "rate.currency",
+			Price:    "rate.amount",
+
+			Stars:     "stars",
+			Amenities: "amenities",
+
+			Refundable: "rate.refundable",
+			Board:      "rate.board",
+		},
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-12-01", Nights: 2, Adults: 2}
+	hotels, err := p.Search(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(hotels) != 2 {
+		t.Fatalf("expected 2 hotels, got %d", len(hotels))
+	}
+	if hotels[0].HotelID != "P1" || hotels[0].Price != 120.50 || hotels[0].Currency != "EUR" ||
+		!hotels[0].Refundable || hotels[0].Board != "BB" {
+		t.Errorf("unexpected first hotel %+v", hotels[0])
+	}
+	if hotels[1].Price != 210 || hotels[1].City != "paris" || hotels[1].Nights != 2 ||
+		hotels[1].Stars != 5 || len(hotels[1].Amenities) != 2 {
+		t.Errorf("unexpected second hotel %+v", hotels[1])
+	}
+}
+
+func TestHTTPProvider_Search_PostBody(t *testing.T) {
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST, got %s", r.Method)
+		}
+		var body struct {
+			City   string `json:"city"`
+			Nights int    `json:"nights"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		if body.City != `o"hare` || body.Nights != 3 {
+			t.Errorf("unexpected body %+v", body)
+		}
+		io.WriteString(w, `[{"code":"X1","price":99}]`)
+	})
+
+	p, err := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:    "supplier-b",
+		Method:  "post",
+		URL:     srv.URL,
+		Body:    `{"city":"{city}","nights":{nights}}`,
+		Mapping: providers.ResponseMapping{HotelID: "code", Price: "price"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &models.SearchRequest{City: `o"hare`, Checkin: "2025-12-01", Nights: 3, Adults: 1}
+	hotels, err := p.Search(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(hotels) != 1 || hotels[0].HotelID != "X1" || hotels[0].Price != 99 {
+		t.Fatalf("unexpected hotels %+v", hotels)
+	}
+}
+
+func TestHTTPProvider_Search_PostBodyTrailingQuote(t *testing.T) {
+	city := `the "loop"`
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			City string `json:"city"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.City != city {
+			t.Errorf("expected city %q, got %q (%v)", city, body.City, err)
+		}
+		io.WriteString(w, `[]`)
+	})
+
+	p, err := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:    "supplier-b",
+		Method:  "post",
+		URL:     srv.URL,
+		Body:    `{"city":"{city}"}`,
+		Mapping: providers.ResponseMapping{HotelID: "code", Price: "price"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &models.SearchRequest{City: city, Checkin: "2025-12-01", Nights: 1, Adults: 1}
+	if _, err := p.Search(context.Background(), req); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestHTTPProvider_Search_DefaultCurrency(t *testing.T) {
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"results":[{"id":"P1","price":90},{"id":"P2","price":80,"currency":"usd"}]}`)
+	})
+	p, _ := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:     "supplier-eur",
+		URL:      srv.URL,
+		Currency: "EUR",
+		Mapping:  providers.ResponseMapping{Results: "results", HotelID: "id", Price: "price", Currency: "currency"},
+	}, srv.Client())
+
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-12-01", Nights: 1, Adults: 1}
+	hotels, err := p.Search(context.Background(), req)
+	if err != nil || len(hotels) != 2 {
+		t.Fatalf("expected 2 hotels, got %v (%v)", hotels, err)
+	}
+	if hotels[0].Currency != "EUR" || hotels[1].Currency != "usd" {
+		t.Fatalf("expected the default only where the item has no currency, got %q and %q", hotels[0].Currency, hotels[1].Currency)
+	}
+}
+
+func TestHTTPProvider_Search_StatusError(t *testing.T) {
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	p, _ := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:    "supplier-c",
+		URL:     srv.URL,
+		Mapping: providers.ResponseMapping{HotelID: "id", Price: "price"},
+	}, srv.Client())
+
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-12-01", Nights: 1, Adults: 1}
+	_, err := p.Search(context.Background(), req)
+	if err == nil {
+		t.Fatal("expected error on 502")
+	}
+	if !search.IsRetryable(err) {
+		t.Fatalf("expected 502 to be retryable, got %v", err)
+	}
+}
+
+func TestHTTPProvider_Search_ClientErrorIsPermanent(t *testing.T) {
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	p, _ := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:    "supplier-d",
+		URL:     srv.URL,
+		Mapping: providers.ResponseMapping{HotelID: "id", Price: "price"},
+	}, srv.Client())
+
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-12-01", Nights: 1, Adults: 1}
+	_, err := p.Search(context.Background(), req)
+	var pe *search.ProviderError
+	if !errors.As(err, &pe) || pe.Retryable || pe.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected permanent 401 provider error, got %v", err)
+	}
+}
+
+func TestHTTPProvider_Search_BodyLimit(t *testing.T) {
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"results":[`+strings.Repeat(`{"id":"h","price":1},`, 1000)+`{"id":"h","price":1}]}`)
+	})
+	p, _ := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:         "supplier-big",
+		URL:          srv.URL,
+		Mapping:      providers.ResponseMapping{Results: "results", HotelID: "id", Price: "price"},
+		MaxBodyBytes: 1024,
+	}, srv.Client())
+
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-12-01", Nights: 1, Adults: 1}
+	_, err := p.Search(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "exceeds 1024 bytes") || search.IsRetryable(err) {
+		t.Fatalf("expected a permanent size error, got %v", err)
+	}
+}
+
+func TestHTTPProvider_Search_HonoursDeadline(t *testing.T) {
+	srv := newSupplier(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+	p, _ := providers.NewHTTPProvider(providers.HTTPProviderConfig{
+		Name:    "supplier-slow",
+		URL:     srv.URL,
+		Mapping: providers.ResponseMapping{HotelID: "id", Price: "price"},
+	}, srv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-12-01", Nights: 1, Adults: 1}
+	start := time.Now()
+	_, err := p.Search(ctx, req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("provider did not honour the context deadline")
+	}
+}
+
+func TestNewHTTPProvider_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  providers.HTTPProviderConfig
+	}{
+		{"MissingName", providers.HTTPProviderConfig{URL: "http://x", Mapping: providers.ResponseMapping{HotelID: "id", Price: "p"}}},
+		{"MissingURL", providers.HTTPProviderConfig{Name: "x", Mapping: providers.ResponseMapping{HotelID: "id", Price: "p"}}},
+		{"MissingPriceMapping", providers.HTTPProviderConfig{Name: "x", URL: "http://x", Mapping: providers.ResponseMapping{HotelID: "id"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := providers.NewHTTPProvider(tt.cfg, nil); err == nil {
+				t.Fatal("expected config error")
+			}
+		})
+	}
+}
@@ -37,13 +37,13 @@ func (m *MockProvider) Search(ctx context.Context, req *models.SearchRequest) ([
 		return nil, ctx.Err()
 	}
 	if ShouldFailFromRng(m.rng, m.failRate) {
-		return nil, errors.New("provider error (simulated)")
+		return nil, search.Retryable(errors.New("provider error (simulated)"))
 	}
 
 	hotels := []search.Hotel{
-		{HotelID: "H123", Name: "Hotel Atlas", City: req.City, Currency: "EUR", Price: 129.90 + float64(m.rng.Intn(30)), Nights: req.Nights},
-		{HotelID: "H234", Name: "Riad Sunset", City: req.City, Currency: "EUR", Price: 99.50 + float64(m.rng.Intn(100)), Nights: req.Nights},
-		{HotelID: "H345", Name: "Kasbah Pearl", City: req.City, Currency: "EUR", Price: 132.00 + float64(m.rng.Intn(40)), Nights: req.Nights},
+		{HotelID: "H123", Name: "Hotel Atlas", City: req.City, Currency: "EUR", Price: 129.90 + float64(m.rng.Intn(30)), Nights: req.Nights, Refundable: m.rng.Intn(2) == 0, Board: "room-only", Stars: 4, Amenities: []string{"wifi", "pool", "spa"}, Rating: 8.6},
+		{HotelID: "H234", Name: "Riad Sunset", City: req.City, Currency: "EUR", Price: 99.50 + float64(m.rng.Intn(100)), Nights: req.Nights, Refundable: m.rng.Intn(2) == 0, Board: "breakfast", Stars: 3, Amenities: []string{"wifi", "breakfast"}, Rating: 9.1},
+		{HotelID: "H345", Name: "Kasbah Pearl", City: req.City, Currency: "EUR", Price: 132.00 + float64(m.rng.Intn(40)), Nights: req.Nights, Refundable: m.rng.Intn(2) == 0, Board: "half-board", Stars: 5, Amenities: []string{"wifi", "pool", "parking"}, Rating: 8.2},
 	}
 
 	return hotels, nil
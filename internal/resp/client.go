@@ -0,0 +1,342 @@
+// Package resp is a minimal client for the Redis serialization protocol
+// (RESP2). It covers the handful of commands the service needs and works
+// against Redis, Valkey, KeyDB and other compatible servers.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNil is returned when the server replies with a nil bulk string, e.g. GET
+// on a missing key.
+var ErrNil = errors.New("resp: nil reply")
+
+// Error is an error reply sent by the server. The connection stays usable.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Client sends commands over a small pool of connections. It is safe for
+// concurrent use.
+type Client struct {
+	addr        string
+	dialTimeout time.Duration
+	opTimeout   time.Duration
+	maxIdle     int
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+type conn struct {
+	nc net.Conn
+	br *bufio.Reader
+	bw *bufio.Writer
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) { c.dialTimeout = d }
+}
+
+// WithOpTimeout bounds a command when the context carries no deadline.
+func WithOpTimeout(d time.Duration) Option {
+	return func(c *Client) { c.opTimeout = d }
+}
+
+func WithMaxIdle(n int) Option {
+	return func(c *Client) { c.maxIdle = n }
+}
+
+func NewClient(addr string, opts ...Option) *Client {
+	c := &Client{addr: addr, dialTimeout: time.Second, opTimeout: time.Second, maxIdle: 8}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Do sends one command and returns its reply: string for simple and bulk
+// strings, int64 for integers and []any for arrays. Nil replies return
+// ErrNil, error replies an Error.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	reply, dropped, err := c.do(ctx, false, args)
+	if dropped && ctx.Err() == nil {
+		// an idle connection the server closed, e.g. on its idle timeout; the
+		// command never ran, so it is safe to send again
+		reply, _, err = c.do(ctx, true, args)
+	}
+	return reply, err
+}
+
+// do runs one command, on a fresh connection if fresh is set. dropped reports
+// a pooled connection found closed by the server.
+func (c *Client) do(ctx context.Context, fresh bool, args []string) (any, bool, error) {
+	cn, pooled, err := c.get(ctx, fresh)
+	if err != nil {
+		return nil, false, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.opTimeout)
+	}
+	cn.nc.SetDeadline(deadline)
+
+	if err := writeCommand(cn.bw, args); err != nil {
+		cn.nc.Close()
+		return nil, pooled, err
+	}
+	reply, err := readReply(cn.br)
+	var respErr Error
+	if err != nil && !errors.Is(err, ErrNil) && !errors.As(err, &respErr) {
+		// the stream is in an unknown state
+		cn.nc.Close()
+		return nil, pooled && closedByPeer(err), err
+	}
+	c.put(cn)
+	return reply, false, err
+}
+
+func closedByPeer(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// Close closes idle connections. Connections in use are closed when their
+// command completes.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cn := range c.idle {
+		cn.nc.Close()
+	}
+	c.idle = nil
+	c.maxIdle = 0
+	return nil
+}
+
+// get returns an idle connection, unless fresh is set, or dials a new one.
+// pooled reports whether the connection was idle.
+func (c *Client) get(ctx context.Context, fresh bool) (cn *conn, pooled bool, err error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 && !fresh {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, true, nil
+	}
+	c.mu.Unlock()
+
+	d := net.Dialer{Timeout: c.dialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, false, err
+	}
+	return &conn{nc: nc, br: bufio.NewReader(nc), bw: bufio.NewWriter(nc)}, false, nil
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= c.maxIdle {
+		cn.nc.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Get returns ErrNil if the key does not exist.
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	v, err := c.Do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("resp: unexpected GET reply %T", v)
+	}
+	return s, nil
+}
+
+// Set stores value with a ttl rounded to milliseconds; ttl <= 0 stores it
+// without expiry.
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// SetNX stores value only if key does not exist and reports whether it did.
+func (c *Client) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	_, err := c.Do(ctx, "SET", key, value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10), "NX")
+	if errors.Is(err, ErrNil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Del removes keys and returns how many existed.
+func (c *Client) Del(ctx context.Context, keys ...string) (int64, error) {
+	v, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := v.(int64)
+	return n, nil
+}
+
+// Scan returns every key matching a glob pattern, iterating SCAN until the
+// server reports the end of the keyspace.
+func (c *Client) Scan(ctx context.Context, match string) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		v, err := c.Do(ctx, "SCAN", cursor, "MATCH", match, "COUNT", "500")
+		if err != nil {
+			return nil, err
+		}
+		reply, ok := v.([]any)
+		if !ok || len(reply) != 2 {
+			return nil, fmt.Errorf("resp: unexpected SCAN reply %v", v)
+		}
+		cursor, _ = reply[0].(string)
+		batch, _ := reply[1].([]any)
+		for _, k := range batch {
+			if s, ok := k.(string); ok {
+				keys = append(keys, s)
+			}
+		}
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// PTTL returns the remaining time to live of key. Missing keys return
+// ErrNil; keys without expiry return -1.
+func (c *Client) PTTL(ctx context.Context, key string) (time.Duration, error) {
+	v, err := c.Do(ctx, "PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+	ms, _ := v.(int64)
+	if ms == -2 {
+		return 0, ErrNil
+	}
+	if ms < 0 {
+		return -1, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// EscapeGlob escapes the characters SCAN and KEYS treat as patterns.
+func EscapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func writeCommand(w *bufio.Writer, args []string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return w.Flush()
+}
+
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("resp: bad bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("resp: bad array length %q", line)
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		// read every element even after an error reply, so the connection
+		// is left at the start of the next reply
+		out := make([]any, n)
+		var replyErr error
+		for i := range out {
+			v, err := readReply(r)
+			var respErr Error
+			switch {
+			case errors.As(err, &respErr):
+				if replyErr == nil {
+					replyErr = err
+				}
+			case err != nil && !errors.Is(err, ErrNil):
+				return nil, err
+			}
+			out[i] = v
+		}
+		if replyErr != nil {
+			return nil, replyErr
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("resp: unknown reply type %q", line[0])
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("resp: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
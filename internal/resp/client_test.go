@@ -0,0 +1,158 @@
+package resp_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/resp"
+	"github.com/example/mini-hotel-aggregator/internal/resp/resptest"
+)
+
+func TestClient_Commands(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := resp.NewClient(srv.Addr())
+	defer c.Close()
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, resp.ErrNil) {
+		t.Fatalf("expected ErrNil for missing key, got %v", err)
+	}
+	value := "multi\r\nline \x00 value"
+	if err := c.Set(ctx, "k", value, time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if got, err := c.Get(ctx, "k"); err != nil || got != value {
+		t.Fatalf("expected %q, got %q (%v)", value, got, err)
+	}
+
+	ok, err := c.SetNX(ctx, "k", "other", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("expected SETNX on existing key to fail, got %v (%v)", ok, err)
+	}
+	ok, err = c.SetNX(ctx, "lock", "me", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected SETNX on new key to succeed, got %v (%v)", ok, err)
+	}
+
+	if n, err := c.Del(ctx, "k", "lock", "missing"); err != nil || n != 2 {
+		t.Fatalf("expected 2 deleted keys, got %d (%v)", n, err)
+	}
+
+	var respErr resp.Error
+	if _, err := c.Do(ctx, "NOPE"); !errors.As(err, &respErr) {
+		t.Fatalf("expected an error reply, got %v", err)
+	}
+	// an error reply leaves the connection usable
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("ping after error reply: %v", err)
+	}
+}
+
+func TestClient_Expiry(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := resp.NewClient(srv.Addr())
+	ctx := context.Background()
+
+	c.Set(ctx, "k", "v", 20*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, resp.ErrNil) {
+		t.Fatalf("expected key to expire, got %v", err)
+	}
+}
+
+func TestClient_ServerDown(t *testing.T) {
+	srv := resptest.NewServer()
+	c := resp.NewClient(srv.Addr(), resp.WithDialTimeout(100*time.Millisecond))
+	ctx := context.Background()
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	if err := c.Ping(ctx); err == nil {
+		t.Fatal("expected an error once the server is gone")
+	}
+}
+
+func TestScript_Run(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := resp.NewClient(srv.Addr())
+	defer c.Close()
+	ctx := context.Background()
+
+	script := resp.NewScript("return redis.call('INCRBY', KEYS[1], ARGV[1])")
+	srv.RegisterScript(script.Source(), func(db *resptest.DB, keys, args []string) any {
+		n, _ := strconv.ParseInt(args[0], 10, 64)
+		cur, _ := db.Get(keys[0])
+		v, _ := strconv.ParseInt(cur, 10, 64)
+		db.Set(keys[0], strconv.FormatInt(v+n, 10), 0)
+		return v + n
+	})
+
+	// the first run uploads the script, the second finds it by digest
+	for i, want := range []int64{2, 4} {
+		got, err := script.Run(ctx, c, []string{"n"}, "2")
+		if err != nil || got != want {
+			t.Fatalf("run %d: expected %d, got %v (%v)", i, want, got, err)
+		}
+	}
+
+	_, err := resp.NewScript("return 1").Run(ctx, c, nil)
+	var respErr resp.Error
+	if !errors.As(err, &respErr) {
+		t.Fatalf("expected an error reply for an unknown script, got %v", err)
+	}
+}
+
+func TestClient_ErrorInsideArrayKeepsConnectionInSync(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := resp.NewClient(srv.Addr())
+	defer c.Close()
+	ctx := context.Background()
+
+	script := resp.NewScript("return {1, redis.error_reply('ERR boom'), 'tail'}")
+	srv.RegisterScript(script.Source(), func(db *resptest.DB, keys, args []string) any {
+		return []any{int64(1), errors.New("ERR boom"), "tail"}
+	})
+	if err := c.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_, err := script.Run(ctx, c, nil)
+	var respErr resp.Error
+	if !errors.As(err, &respErr) || string(respErr) != "ERR boom" {
+		t.Fatalf("expected the element's error reply, got %v", err)
+	}
+	// the rest of the array must not leak into the next reply
+	if v, err := c.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("expected v from the pooled connection, got %q (%v)", v, err)
+	}
+}
+
+func TestClient_RedialsDroppedIdleConnection(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := resp.NewClient(srv.Addr())
+	defer c.Close()
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.DropConnections()
+	if err := c.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("expected the command to be sent again on a fresh connection, got %v", err)
+	}
+	if v, ok := srv.Get("k"); !ok || v != "v" {
+		t.Fatalf("expected k to be set once, got %q", v)
+	}
+}
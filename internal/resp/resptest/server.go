@@ -0,0 +1,441 @@
+// Package resptest provides an in-memory RESP server for tests, in the
+// spirit of net/http/httptest. It implements the subset of Redis commands
+// the service uses. Lua scripts cannot run here; tests register a Go
+// equivalent with RegisterScript.
+package resptest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type item struct {
+	val      string
+	expireAt time.Time // zero means no expiry
+}
+
+// Server is an in-memory stand-in for a Redis server listening on a local
+// port.
+type Server struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	data    map[string]item
+	scripts map[string]ScriptFunc // by SHA1 of the Lua source
+	loaded  map[string]bool       // scripts sent with EVAL, runnable by EVALSHA
+	conns   map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+// ScriptFunc stands in for a Lua script, which the server cannot run. It is
+// called atomically with the keyspace and returns the script's reply: a
+// string, int64, error, []any or nil.
+type ScriptFunc func(db *DB, keys, args []string) any
+
+// DB is the keyspace as seen by a ScriptFunc.
+type DB struct{ s *Server }
+
+func (db *DB) Get(key string) (string, bool) {
+	it, ok := db.s.lookup(key)
+	return it.val, ok
+}
+
+// Set stores val; ttl <= 0 stores it without expiry.
+func (db *DB) Set(key, val string, ttl time.Duration) {
+	it := item{val: val}
+	if ttl > 0 {
+		it.expireAt = time.Now().Add(ttl)
+	}
+	db.s.data[key] = it
+}
+
+// Now is the server time, as returned by TIME.
+func (db *DB) Now() time.Time { return time.Now() }
+
+// RegisterScript makes EVAL of the Lua source src run fn. As with Redis,
+// EVALSHA only finds the script once it has been sent with EVAL.
+func (s *Server) RegisterScript(src string, fn ScriptFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := sha1.Sum([]byte(src))
+	s.scripts[hex.EncodeToString(sum[:])] = fn
+}
+
+// NewServer starts a server on a random loopback port. Callers should Close
+// it when done.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("resptest: failed to listen: %v", err))
+	}
+	s := &Server{ln: ln, data: make(map[string]item), scripts: make(map[string]ScriptFunc), loaded: make(map[string]bool), conns: make(map[net.Conn]struct{})}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr is the host:port to connect to.
+func (s *Server) Addr() string { return s.ln.Addr().String() }
+
+// Close stops the listener and drops every open connection, which looks to
+// clients like the backend going away.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// DropConnections closes every open connection but keeps listening, as a
+// server enforcing an idle timeout does.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Get reads a key directly, bypassing the protocol.
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.lookup(key)
+	return it.val, ok
+}
+
+// Keys lists live keys in sorted order.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys("*")
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.exec(w, args)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("resptest: expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimRight(line, "\r\n")[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (s *Server) exec(w *bufio.Writer, args []string) {
+	if len(args) == 0 {
+		writeError(w, "ERR empty command")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd := strings.ToUpper(args[0]); cmd {
+	case "PING":
+		fmt.Fprint(w, "+PONG\r\n")
+	case "GET":
+		if len(args) != 2 {
+			writeArity(w, cmd)
+			return
+		}
+		if it, ok := s.lookup(args[1]); ok {
+			writeBulk(w, it.val)
+		} else {
+			writeNil(w)
+		}
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		var n int
+		for _, k := range args[1:] {
+			if _, ok := s.lookup(k); ok {
+				delete(s.data, k)
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "EXISTS":
+		var n int
+		for _, k := range args[1:] {
+			if _, ok := s.lookup(k); ok {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "PTTL":
+		if len(args) != 2 {
+			writeArity(w, cmd)
+			return
+		}
+		it, ok := s.lookup(args[1])
+		switch {
+		case !ok:
+			writeInt(w, -2)
+		case it.expireAt.IsZero():
+			writeInt(w, -1)
+		default:
+			writeInt(w, int(time.Until(it.expireAt).Milliseconds()))
+		}
+	case "KEYS":
+		if len(args) != 2 {
+			writeArity(w, cmd)
+			return
+		}
+		writeArray(w, s.keys(args[1]))
+	case "SCAN":
+		// Everything is returned in one pass with a terminal cursor.
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i], "MATCH") {
+				pattern = args[i+1]
+			}
+		}
+		fmt.Fprint(w, "*2\r\n")
+		writeBulk(w, "0")
+		writeArray(w, s.keys(pattern))
+	case "EVAL", "EVALSHA":
+		s.eval(w, cmd, args)
+	case "FLUSHDB", "FLUSHALL":
+		s.data = make(map[string]item)
+		fmt.Fprint(w, "+OK\r\n")
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+}
+
+// set implements SET key value [EX seconds | PX milliseconds] [NX | XX].
+func (s *Server) set(w *bufio.Writer, args []string) {
+	if len(args) < 3 {
+		writeArity(w, "SET")
+		return
+	}
+	key, it := args[1], item{val: args[2]}
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Millisecond
+			if opt == "EX" {
+				unit = time.Second
+			}
+			it.expireAt = time.Now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+	_, exists := s.lookup(key)
+	if (nx && exists) || (xx && !exists) {
+		writeNil(w)
+		return
+	}
+	s.data[key] = it
+	fmt.Fprint(w, "+OK\r\n")
+}
+
+// eval implements EVAL script numkeys key... arg... and EVALSHA with a
+// registered script.
+func (s *Server) eval(w *bufio.Writer, cmd string, args []string) {
+	if len(args) < 3 {
+		writeArity(w, cmd)
+		return
+	}
+	sha := args[1]
+	if cmd == "EVAL" {
+		sum := sha1.Sum([]byte(args[1]))
+		sha = hex.EncodeToString(sum[:])
+	}
+	fn, ok := s.scripts[sha]
+	switch {
+	case cmd == "EVALSHA" && !s.loaded[sha]:
+		writeError(w, "NOSCRIPT No matching script. Please use EVAL.")
+		return
+	case !ok:
+		writeError(w, "ERR resptest: script not registered")
+		return
+	}
+	s.loaded[sha] = true
+	n, err := strconv.Atoi(args[2])
+	if err != nil || n < 0 || 3+n > len(args) {
+		writeError(w, "ERR Number of keys can't be greater than number of args")
+		return
+	}
+	writeValue(w, fn(&DB{s: s}, args[3:3+n], args[3+n:]))
+}
+
+// lookup returns a live key, dropping it if it has expired. Callers hold mu.
+func (s *Server) lookup(key string) (item, bool) {
+	it, ok := s.data[key]
+	if ok && !it.expireAt.IsZero() && !time.Now().Before(it.expireAt) {
+		delete(s.data, key)
+		return item{}, false
+	}
+	return it, ok
+}
+
+func (s *Server) keys(pattern string) []string {
+	var out []string
+	for k := range s.data {
+		if _, ok := s.lookup(k); !ok {
+			continue
+		}
+		if match(pattern, k) {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// match implements the glob subset Redis supports for key patterns: *, ?
+// and backslash escapes.
+func match(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+func writeBulk(w *bufio.Writer, s string) { fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s) }
+func writeNil(w *bufio.Writer)            { fmt.Fprint(w, "$-1\r\n") }
+func writeInt(w *bufio.Writer, n int)     { fmt.Fprintf(w, ":%d\r\n", n) }
+func writeError(w *bufio.Writer, msg string) {
+	fmt.Fprintf(w, "-%s\r\n", msg)
+}
+
+func writeArity(w *bufio.Writer, cmd string) {
+	writeError(w, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
+
+func writeValue(w *bufio.Writer, v any) {
+	switch v := v.(type) {
+	case nil:
+		writeNil(w)
+	case string:
+		writeBulk(w, v)
+	case error:
+		writeError(w, v.Error())
+	case int64:
+		writeInt(w, int(v))
+	case []any:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, e := range v {
+			writeValue(w, e)
+		}
+	default:
+		writeError(w, fmt.Sprintf("ERR resptest: unsupported script reply %T", v))
+	}
+}
+
+func writeArray(w *bufio.Writer, vals []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(vals))
+	for _, v := range vals {
+		writeBulk(w, v)
+	}
+}
@@ -0,0 +1,38 @@
+package resp
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Script is a Lua script run atomically by the server. It is sent by its
+// SHA1 digest and only uploaded when the server does not know it yet.
+type Script struct {
+	src string
+	sha string
+}
+
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// Source is the Lua source of the script.
+func (s *Script) Source() string { return s.src }
+
+// Run evaluates the script with keys and args and returns its reply as Do
+// does.
+func (s *Script) Run(ctx context.Context, c *Client, keys []string, args ...string) (any, error) {
+	params := append([]string{strconv.Itoa(len(keys))}, keys...)
+	params = append(params, args...)
+	v, err := c.Do(ctx, append([]string{"EVALSHA", s.sha}, params...)...)
+	var respErr Error
+	if errors.As(err, &respErr) && strings.HasPrefix(string(respErr), "NOSCRIPT") {
+		return c.Do(ctx, append([]string{"EVAL", s.src}, params...)...)
+	}
+	return v, err
+}
@@ -7,11 +7,15 @@ import (
 	handlers "github.com/example/mini-hotel-aggregator/internal/http"
 	mid "github.com/example/mini-hotel-aggregator/internal/middleware"
 	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/search"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func GetRoutes(h *handlers.Handler, metrics *obs.Metrics, logger *slog.Logger) *chi.Mux {
+// GetRoutes builds the router. adminToken guards the /admin endpoints; when
+// empty they are disabled. rl limits /search and /admin, each under its own
+// policy; keys, which may be nil, identifies /search clients by API key.
+func GetRoutes(h *handlers.Handler, metrics *obs.Metrics, logger *slog.Logger, adminToken string, rl search.RateLimiter, keys *search.APIKeyStore) *chi.Mux {
 	r := chi.NewRouter()
 	// Useful built-in middlewares
 	r.Use(middleware.RealIP)    // proper client IP extraction
@@ -23,10 +27,30 @@ func GetRoutes(h *handlers.Handler, metrics *obs.Metrics, logger *slog.Logger) *
 	r.Use(mid.LoggingMiddleware(logger))
 	r.Use(mid.TimeoutMiddleware(10 * time.Second))
 
-	// endpoints
-	r.Get("/search", h.Search)
+	// endpoints; probes and scrapes are not rate limited
+	r.With(mid.RateLimit(mid.RateLimitPolicy{
+		Route:   "search",
+		Limiter: rl,
+		Plan:    search.DefaultPlan,
+		APIKeys: keys,
+	}, metrics)).Get("/search", h.Search)
 	r.Get("/healthz", h.Healthz)
 	r.Get("/metrics", metrics.Handler().ServeHTTP)
 
+	r.Route("/admin", func(r chi.Router) {
+		// limited ahead of auth to slow down token guessing
+		r.Use(mid.RateLimit(mid.RateLimitPolicy{
+			Route:   "admin",
+			Limiter: rl,
+			Plan:    search.Plan{Name: "admin", RequestsPerMinute: 60, Burst: 20},
+		}, metrics))
+		r.Use(mid.AdminAuth(adminToken))
+		r.Get("/cache", h.ListCache)
+		r.Delete("/cache", h.FlushCache)
+		r.Get("/cache/entry", h.GetCacheEntry)
+		r.Delete("/cache/entry", h.DeleteCacheEntry)
+		r.Delete("/cache/cities/{city}", h.PurgeCity)
+	})
+
 	return r
 }
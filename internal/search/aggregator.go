@@ -2,7 +2,10 @@ package search
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -21,12 +24,118 @@ type aggregator struct {
 	providers []Provider
 	timeout   time.Duration
 	metrics   *obs.Metrics
+	breakers  map[string]*circuitBreaker
+	retry     *RetryPolicy
+	hedge     *HedgeConfig
+	resolver  *HotelResolver
+	fx        CurrencyConverter
+	currency  string
+	slices    *providerCache
 }
 
-func NewAggregator(providers []Provider, timeout time.Duration, m *obs.Metrics) *aggregator {
-	return &aggregator{providers: providers, timeout: timeout, metrics: m}
+// CurrencyConverter converts offer prices into the display currency.
+type CurrencyConverter interface {
+	Supports(code string) bool
+	Convert(amount float64, from, to string) (float64, error)
 }
 
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// ErrAllProvidersFailed is returned when no provider answered, so caches keep
+// serving the last good result instead of storing an empty one.
+var ErrAllProvidersFailed = errors.New("all providers failed")
+
+// AggregatorOption configures optional aggregator behaviour.
+type AggregatorOption func(*aggregator)
+
+// WithCircuitBreaker gives every provider its own circuit breaker; providers
+// with an open circuit are skipped instead of called.
+func WithCircuitBreaker(cfg BreakerConfig) AggregatorOption {
+	return func(a *aggregator) {
+		a.breakers = make(map[string]*circuitBreaker, len(a.providers))
+		for _, p := range a.providers {
+			name := p.Name()
+			a.breakers[name] = newCircuitBreaker(cfg, func(s breakerState) {
+				a.metrics.SetProviderBreakerState(name, float64(s))
+			})
+			a.metrics.SetProviderBreakerState(name, float64(breakerClosed))
+		}
+	}
+}
+
+// WithRetry retries transient provider errors according to p.
+func WithRetry(p RetryPolicy) AggregatorOption {
+	return func(a *aggregator) {
+		a.retry = &p
+	}
+}
+
+// WithHedging races slow provider calls against a second identical call once
+// they exceed the provider's observed latency quantile.
+func WithHedging(cfg HedgeConfig) AggregatorOption {
+	return func(a *aggregator) {
+		a.hedge = &cfg
+	}
+}
+
+// WithHotelResolver merges the same property offered under different
+// provider ids into one hotel.
+func WithHotelResolver(r *HotelResolver) AggregatorOption {
+	return func(a *aggregator) {
+		a.resolver = r
+	}
+}
+
+// WithCurrencyConverter converts every offer to the requested currency, or to
+// defaultCurrency when the request does not name one, before merging and
+// sorting.
+func WithCurrencyConverter(c CurrencyConverter, defaultCurrency string) AggregatorOption {
+	return func(a *aggregator) {
+		a.fx = c
+		a.currency = defaultCurrency
+	}
+}
+
+// WithProviderCache keeps each provider's answer for ttl so a search only
+// re-queries providers whose answer is missing, expired or failed. ttl should
+// not exceed the result cache TTL, or expired results are rebuilt from old
+// answers. The cache warmer always queries every provider.
+func WithProviderCache(ttl time.Duration) AggregatorOption {
+	return func(a *aggregator) {
+		a.slices = newProviderCache(ttl, DefaultCacheMaxEntries*len(a.providers))
+	}
+}
+
+func NewAggregator(providers []Provider, timeout time.Duration, m *obs.Metrics, opts ...AggregatorOption) *aggregator {
+	a := &aggregator{providers: providers, timeout: timeout, metrics: m}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Run drops expired provider answers every interval until ctx is cancelled.
+// Without a provider cache it returns at once.
+func (a *aggregator) Run(ctx context.Context, interval time.Duration) {
+	if a.slices == nil {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			a.slices.sweep()
+		}
+	}
+}
+
+// DefaultCurrency is the currency requests without one are converted to,
+// empty when prices are not converted.
+func (a *aggregator) DefaultCurrency() string { return a.currency }
+
 func normalizeHotel(h Hotel) (Hotel, bool) {
 
 	h.HotelID = strings.TrimSpace(h.HotelID)
@@ -35,18 +144,158 @@ func normalizeHotel(h Hotel) (Hotel, bool) {
 	}
 	// normalize casing for city
 	h.City = strings.ToLower(strings.TrimSpace(h.City))
+	h.Currency = strings.ToUpper(strings.TrimSpace(h.Currency))
+	amenities := make([]string, 0, len(h.Amenities))
+	for _, am := range h.Amenities {
+		if am = strings.ToLower(strings.TrimSpace(am)); am != "" && !slices.Contains(amenities, am) {
+			amenities = append(amenities, am)
+		}
+	}
+	h.Amenities = amenities
 	return h, true
 }
 
+func newOffer(h Hotel, provider string) Offer {
+	return Offer{
+		Provider:   provider,
+		HotelID:    h.HotelID,
+		Price:      h.Price,
+		Currency:   h.Currency,
+		Refundable: h.Refundable,
+		Board:      h.Board,
+	}
+}
+
+// convertOffer rewrites the offer price into currency, keeping the supplier's
+// original amount. Offers that cannot be converted are dropped.
+func (a *aggregator) convertOffer(o *Offer, currency string) bool {
+	price, err := a.fx.Convert(o.Price, o.Currency, currency)
+	if err != nil {
+		// counted rather than logged, a supplier without a currency would
+		// log every offer of every search
+		a.metrics.IncProviderOfferDropped(o.Provider)
+		return false
+	}
+	o.OriginalPrice, o.OriginalCurrency = o.Price, o.Currency
+	o.Price, o.Currency = price, currency
+	return true
+}
+
+// callProvider runs a single provider search, hedging slow calls and retrying
+// transient failures when configured. Latency is observed per call.
+func (a *aggregator) callProvider(ctx context.Context, pr Provider, req *models.SearchRequest) ([]Hotel, error) {
+	attempt := func(ctx context.Context) ([]Hotel, error) {
+		start := time.Now()
+		hs, err := pr.Search(ctx, req)
+		// a call cut short by the hedge says nothing about the provider and
+		// would drag down the quantile the hedge delay is read from
+		if !hedgeLost(ctx) {
+			a.metrics.ObserveProviderLatency(pr.Name(), float64(time.Since(start).Microseconds())/1000)
+		}
+		return hs, err
+	}
+	if a.hedge != nil {
+		attempt = a.hedged(pr.Name(), attempt)
+	}
+	if a.retry == nil {
+		return attempt(ctx)
+	}
+	return a.retry.do(ctx, attempt, func() {
+		a.metrics.IncProviderRetry(pr.Name())
+	})
+}
+
+// mergeOffer adds offer to the hotel under the canonical id and keeps the
+// hotel's headline fields pointing at the cheapest offer.
+func mergeOffer(all map[string]*Hotel, id string, h Hotel, offer Offer) {
+	existing, found := all[id]
+	if !found {
+		h.HotelID = id
+		h.Price = offer.Price
+		h.Currency = offer.Currency
+		h.Provider = offer.Provider
+		h.Offers = []Offer{offer}
+		all[id] = &h
+		return
+	}
+	existing.Offers = append(existing.Offers, offer)
+	// suppliers describe the property unevenly, fill what is missing
+	if existing.Stars == 0 {
+		existing.Stars = h.Stars
+	}
+	if existing.Rating == 0 {
+		existing.Rating = h.Rating
+	}
+	existing.Amenities = mergeAmenities(existing.Amenities, h.Amenities)
+	if offer.Price < existing.Price {
+		existing.Price = offer.Price
+		existing.Currency = offer.Currency
+		existing.Provider = offer.Provider
+		existing.Refundable = offer.Refundable
+		existing.Board = offer.Board
+	}
+}
+
+func mergeAmenities(have, more []string) []string {
+	for _, m := range more {
+		if !slices.Contains(have, m) {
+			have = append(have, m)
+		}
+	}
+	return have
+}
+
 func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (AggregatedResult, error) {
 	start := time.Now()
+	currency := req.Currency
+	if currency == "" {
+		currency = a.currency
+	}
+	if currency != "" && (a.fx == nil || !a.fx.Supports(currency)) {
+		return AggregatedResult{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+
+	parent := ctx
 	ctx, cancel := context.WithTimeout(ctx, a.timeout)
 	defer cancel()
 
+	// answers are merged once all are in, in provider order, so hotel
+	// matching does not depend on which provider answered first
+	var results []ProviderResult
+
+	// slices hold unconverted prices, so every display currency shares them
+	var sliceKey string
+	useSlices := a.slices != nil && !isWarming(ctx)
+	if a.slices != nil {
+		raw := *req
+		raw.Currency = ""
+		sliceKey = raw.CacheKey()
+	}
+	// the result is as old as the oldest answer it is built from
+	computedAt := time.Now()
+
 	resCh := make(chan ProviderResult, len(a.providers))
 	errCh := make(chan struct{}, len(a.providers)) // only count failures
 	var wg sync.WaitGroup
+	launched := 0
+	providersSkipped := 0
+	providersCached := 0
 	for _, p := range a.providers {
+		if useSlices {
+			if hs, storedAt, ok := a.slices.get(p.Name(), sliceKey); ok {
+				computedAt = minTime(computedAt, storedAt)
+				a.metrics.IncProviderCacheHit(p.Name())
+				results = append(results, ProviderResult{Provider: p.Name(), Hotels: hs})
+				providersCached++
+				continue
+			}
+		}
+		cb := a.breakers[p.Name()]
+		if cb != nil && !cb.allow() {
+			providersSkipped++
+			continue
+		}
+		launched++
 		wg.Add(1)
 		prov := p
 		go func(pr Provider) {
@@ -56,6 +305,9 @@ func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (Agg
 				if r := recover(); r != nil {
 					log.Printf("provider %s panic recovered: %v", pr.Name(), r)
 					a.metrics.IncProviderFailure(pr.Name())
+					if cb != nil {
+						cb.record(false)
+					}
 					// non-blocking signal of failure
 					select {
 					case errCh <- struct{}{}:
@@ -63,10 +315,16 @@ func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (Agg
 					}
 				}
 			}()
-			start := time.Now()
-			hs, err := pr.Search(ctx, req)
-			duration := time.Since(start).Seconds()
-			a.metrics.ObserveProviderLatency(pr.Name(), duration)
+			hs, err := a.callProvider(ctx, pr, req)
+
+			// a caller that went away says nothing about the provider's health
+			if cb != nil {
+				if parent.Err() == nil {
+					cb.record(err == nil)
+				} else {
+					cb.abort()
+				}
+			}
 
 			if err != nil {
 				a.metrics.IncProviderFailure(pr.Name())
@@ -77,6 +335,9 @@ func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (Agg
 				}
 				return
 			}
+			if a.slices != nil {
+				a.slices.put(pr.Name(), sliceKey, hs)
+			}
 			select {
 			case resCh <- ProviderResult{Provider: pr.Name(), Hotels: hs}:
 			case <-ctx.Done():
@@ -91,8 +352,7 @@ func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (Agg
 		close(errCh)
 	}()
 
-	all := map[string]Hotel{}
-	providersSucceeded := 0
+	providersSucceeded := providersCached
 	providersFailed := 0
 	// Collect until channels closed or context done
 	for resCh != nil || errCh != nil {
@@ -103,15 +363,7 @@ func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (Agg
 				continue
 			}
 			providersSucceeded++
-			for _, h := range pr.Hotels {
-				nh, ok := normalizeHotel(h)
-				if !ok {
-					continue
-				}
-				if existing, found := all[nh.HotelID]; !found || nh.Price < existing.Price {
-					all[nh.HotelID] = nh
-				}
-			}
+			results = append(results, pr)
 		case _, ok := <-errCh:
 			if !ok {
 				errCh = nil
@@ -122,7 +374,7 @@ func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (Agg
 			// treat remaining as failed
 			if resCh != nil || errCh != nil {
 				// count remaining providers that didn't respond as failures
-				remaining := len(a.providers) - (providersSucceeded + providersFailed)
+				remaining := launched - (providersSucceeded - providersCached + providersFailed)
 				if remaining > 0 {
 					providersFailed += remaining
 				}
@@ -132,19 +384,66 @@ func (a *aggregator) Search(ctx context.Context, req *models.SearchRequest) (Agg
 		}
 	}
 
+	if len(a.providers) > 0 && providersSucceeded == 0 {
+		// blame the caller's deadline rather than the providers
+		if err := parent.Err(); err != nil {
+			return AggregatedResult{}, err
+		}
+		return AggregatedResult{}, fmt.Errorf("%w: %d failed, %d skipped", ErrAllProvidersFailed, providersFailed, providersSkipped)
+	}
+
+	all := map[string]*Hotel{}
+	matcher := newMatchSession(a.resolver)
+	sort.Slice(results, func(i, j int) bool { return results[i].Provider < results[j].Provider })
+	for _, pr := range results {
+		for _, h := range pr.Hotels {
+			nh, ok := normalizeHotel(h)
+			if !ok {
+				continue
+			}
+			offer := newOffer(nh, pr.Provider)
+			if currency != "" && !a.convertOffer(&offer, currency) {
+				continue
+			}
+			mergeOffer(all, matcher.canonicalID(pr.Provider, nh), nh, offer)
+		}
+	}
+
 	// build result list
 	hotels := make([]Hotel, 0, len(all))
 	for _, v := range all {
-		hotels = append(hotels, v)
+		sort.Slice(v.Offers, func(i, j int) bool {
+			if v.Offers[i].Price != v.Offers[j].Price {
+				return v.Offers[i].Price < v.Offers[j].Price
+			}
+			return v.Offers[i].Provider < v.Offers[j].Provider
+		})
+		hotels = append(hotels, *v)
 	}
-	sort.Slice(hotels, func(i, j int) bool { return hotels[i].Price < hotels[j].Price })
+	sort.Slice(hotels, func(i, j int) bool {
+		if hotels[i].Price != hotels[j].Price {
+			return hotels[i].Price < hotels[j].Price
+		}
+		return hotels[i].HotelID < hotels[j].HotelID
+	})
 
 	out := AggregatedResult{}
 	out.Stats.ProvidersTotal = len(a.providers)
 	out.Stats.ProvidersSucceeded = providersSucceeded
 	out.Stats.ProvidersFailed = providersFailed
+	out.Stats.ProvidersSkipped = providersSkipped
+	out.Stats.ProvidersCached = providersCached
 	out.Stats.Cache = "miss"
 	out.Stats.DurationMs = time.Since(start).Milliseconds()
+	out.Currency = currency
+	out.ComputedAt = computedAt
 	out.Hotels = hotels
 	return out, nil
 }
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -2,12 +2,15 @@ package search
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/example/mini-hotel-aggregator/internal/models"
 	"github.com/example/mini-hotel-aggregator/internal/obs"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // basic test using deterministic provider
@@ -84,3 +87,213 @@ func TestAggregator_NormalizesAndDeduplicates(t *testing.T) {
 		}
 	}
 }
+
+type failingProvider struct {
+	name  string
+	calls int
+}
+
+func (f *failingProvider) Search(ctx context.Context, req *models.SearchRequest) ([]Hotel, error) {
+	f.calls++
+	return nil, errors.New("boom")
+}
+func (f *failingProvider) Name() string { return f.name }
+
+func TestAggregator_SkipsOpenCircuit(t *testing.T) {
+	bad := &failingProvider{name: "bad"}
+	good := &staticProvider{"good", []Hotel{{HotelID: "H1", Name: "A", Price: 100}}}
+	cfg := BreakerConfig{ConsecutiveFailures: 2, CoolDown: time.Minute}
+	agg := NewAggregator([]Provider{bad, good}, time.Second, obs.NewMetrics(prometheus.NewRegistry()), WithCircuitBreaker(cfg))
+	req := &models.SearchRequest{City: "city", Checkin: "2025-11-20", Nights: 1, Adults: 2}
+
+	for i := 0; i < 2; i++ {
+		res, err := agg.Search(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Stats.ProvidersFailed != 1 || res.Stats.ProvidersSkipped != 0 {
+			t.Fatalf("run %d: unexpected stats %+v", i, res.Stats)
+		}
+	}
+
+	res, err := agg.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad.calls != 2 {
+		t.Fatalf("expected open provider not to be called, got %d calls", bad.calls)
+	}
+	if res.Stats.ProvidersSkipped != 1 || res.Stats.ProvidersFailed != 0 || res.Stats.ProvidersSucceeded != 1 {
+		t.Fatalf("unexpected stats %+v", res.Stats)
+	}
+	if len(res.Hotels) != 1 {
+		t.Fatalf("expected healthy provider results, got %+v", res.Hotels)
+	}
+}
+
+func TestAggregator_KeepsAllOffers(t *testing.T) {
+	providers := []Provider{
+		&staticProvider{"p1", []Hotel{{HotelID: "H1", Name: "A", Price: 100, Currency: "EUR", Refundable: true}}},
+		&staticProvider{"p2", []Hotel{{HotelID: "H1", Name: "A", Price: 90, Currency: "EUR", Board: "breakfast"}}},
+		&staticProvider{"p3", []Hotel{{HotelID: "H1", Name: "A", Price: 95, Currency: "EUR"}}},
+	}
+	agg := NewAggregator(providers, time.Second, obs.NewMetrics(prometheus.NewRegistry()))
+	res, err := agg.Search(context.Background(), &models.SearchRequest{City: "c", Checkin: "2025-11-20", Nights: 1, Adults: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hotels) != 1 {
+		t.Fatalf("expected 1 hotel, got %d", len(res.Hotels))
+	}
+	h := res.Hotels[0]
+	if h.Price != 90 || h.Provider != "p2" || h.Board != "breakfast" || h.Refundable {
+		t.Fatalf("headline should be the cheapest offer, got %+v", h)
+	}
+	if len(h.Offers) != 3 {
+		t.Fatalf("expected 3 offers, got %+v", h.Offers)
+	}
+	wantOrder := []string{"p2", "p3", "p1"}
+	for i, o := range h.Offers {
+		if o.Provider != wantOrder[i] {
+			t.Fatalf("offers not sorted by price: %+v", h.Offers)
+		}
+	}
+	if !h.Offers[2].Refundable {
+		t.Fatalf("offer conditions lost: %+v", h.Offers[2])
+	}
+}
+
+// fixedRates converts with a static table of units per EUR.
+type fixedRates map[string]float64
+
+func (f fixedRates) Supports(code string) bool {
+	_, ok := f[code]
+	return ok
+}
+func (f fixedRates) Convert(amount float64, from, to string) (float64, error) {
+	if _, ok := f[from]; !ok {
+		return 0, errors.New("unknown currency " + from)
+	}
+	return amount / f[from] * f[to], nil
+}
+
+func TestAggregator_ConvertsCurrencyBeforeMerge(t *testing.T) {
+	providers := []Provider{
+		&staticProvider{"p1", []Hotel{{HotelID: "H1", Name: "A", Price: 100, Currency: "EUR"}}},
+		&staticProvider{"p2", []Hotel{
+			{HotelID: "H1", Name: "A", Price: 180, Currency: "usd"}, // 90 EUR, cheaper after conversion
+			{HotelID: "H2", Name: "B", Price: 150, Currency: "EUR"},
+			{HotelID: "H3", Name: "C", Price: 10, Currency: "XXX"}, // unknown, dropped
+		}},
+	}
+	rates := fixedRates{"EUR": 1, "USD": 2}
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	agg := NewAggregator(providers, time.Second, m, WithCurrencyConverter(rates, "EUR"))
+	req := &models.SearchRequest{City: "c", Checkin: "2025-11-20", Nights: 1, Adults: 1}
+
+	res, err := agg.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(m.ProviderOffersDropped.WithLabelValues("p2")); got != 1 {
+		t.Fatalf("expected the unconvertible offer to be counted, got %v", got)
+	}
+	if res.Currency != "EUR" || len(res.Hotels) != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	h := res.Hotels[0]
+	if h.HotelID != "H1" || h.Price != 90 || h.Currency != "EUR" || h.Provider != "p2" {
+		t.Fatalf("expected converted cheapest offer first, got %+v", h)
+	}
+	if o := h.Offers[0]; o.OriginalPrice != 180 || o.OriginalCurrency != "USD" {
+		t.Fatalf("original price not kept: %+v", o)
+	}
+
+	req.Currency = "USD"
+	res, _ = agg.Search(context.Background(), req)
+	if res.Currency != "USD" || res.Hotels[0].Price != 180 {
+		t.Fatalf("expected prices in USD, got %+v", res.Hotels[0])
+	}
+
+	req.Currency = "GBP"
+	if _, err := agg.Search(context.Background(), req); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Fatalf("expected unsupported currency error, got %v", err)
+	}
+}
+
+func TestAggregator_ProviderCache(t *testing.T) {
+	var healthyCalls int32
+	healthy := &countingProvider{name: "p1", calls: &healthyCalls, hotels: []Hotel{{HotelID: "H2", Price: 90}}}
+	flaky := &flakyProvider{name: "p2", n: 1, err: errors.New("provider down")}
+	agg := NewAggregator([]Provider{healthy, flaky}, time.Second, obs.NewMetrics(prometheus.NewRegistry()), WithProviderCache(time.Minute))
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-11-20", Nights: 1, Adults: 2}
+
+	res, _ := agg.Search(context.Background(), req)
+	if res.Stats.ProvidersFailed != 1 || res.Stats.ProvidersCached != 0 || len(res.Hotels) != 1 {
+		t.Fatalf("expected a degraded first result, got %+v", res.Stats)
+	}
+
+	// only the failed provider is queried again
+	res, _ = agg.Search(context.Background(), req)
+	if res.Stats.ProvidersSucceeded != 2 || res.Stats.ProvidersCached != 1 || len(res.Hotels) != 2 {
+		t.Fatalf("expected cached p1 merged with fresh p2, got %+v", res.Stats)
+	}
+	if n := atomic.LoadInt32(&healthyCalls); n != 1 {
+		t.Fatalf("expected the healthy provider to be called once, got %d", n)
+	}
+
+	// a different search has its own slices
+	other := *req
+	other.Checkin = "2025-11-21"
+	agg.Search(context.Background(), &other)
+	if n := atomic.LoadInt32(&healthyCalls); n != 2 {
+		t.Fatalf("expected a new search to call the provider, got %d calls", n)
+	}
+}
+
+func TestAggregator_ProviderCacheSkippedWhenWarming(t *testing.T) {
+	var calls int32
+	p := &countingProvider{name: "p1", calls: &calls, hotels: []Hotel{{HotelID: "H1", Price: 90}}}
+	agg := NewAggregator([]Provider{p}, time.Second, obs.NewMetrics(prometheus.NewRegistry()), WithProviderCache(time.Minute))
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-11-20", Nights: 1, Adults: 2}
+
+	agg.Search(context.Background(), req)
+	// a warm run must not renew a result from answers it already holds
+	agg.Search(withWarming(context.Background(), 0), req)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected the warm run to call the provider, got %d calls", n)
+	}
+}
+
+func TestAggregator_ProviderCacheSharedAcrossCurrencies(t *testing.T) {
+	var calls int32
+	p := &countingProvider{name: "p1", calls: &calls, hotels: []Hotel{{HotelID: "H1", Price: 90, Currency: "EUR"}}}
+	rates := fixedRates{"EUR": 1, "USD": 2}
+	agg := NewAggregator([]Provider{p}, time.Second, obs.NewMetrics(prometheus.NewRegistry()),
+		WithProviderCache(time.Minute), WithCurrencyConverter(rates, "EUR"))
+	req := &models.SearchRequest{City: "paris", Checkin: "2025-11-20", Nights: 1, Adults: 2}
+
+	agg.Search(context.Background(), req)
+	usd := *req
+	usd.Currency = "USD"
+	res, err := agg.Search(context.Background(), &usd)
+	if err != nil || res.Stats.ProvidersCached != 1 || res.Hotels[0].Price != 180 {
+		t.Fatalf("expected the cached answer converted to USD, got %+v, %v", res, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected one provider call for both currencies, got %d", n)
+	}
+}
+
+type countingProvider struct {
+	name   string
+	calls  *int32
+	hotels []Hotel
+}
+
+func (c *countingProvider) Search(ctx context.Context, req *models.SearchRequest) ([]Hotel, error) {
+	atomic.AddInt32(c.calls, 1)
+	return c.hotels, nil
+}
+
+func (c *countingProvider) Name() string { return c.name }
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Client is a caller identified by API key.
+type Client struct {
+	ID   string
+	Plan Plan
+}
+
+// APIKeyStore maps API keys to clients. Only SHA-256 digests of the keys are
+// kept, so the key file never holds usable secrets.
+type APIKeyStore struct {
+	clients map[string]Client // by hex sha256 of the key
+}
+
+type apiKeyFile struct {
+	Plans map[string]Plan `json:"plans"`
+	Keys  []struct {
+		KeySHA256 string `json:"key_sha256"`
+		Client    string `json:"client"`
+		Plan      string `json:"plan"`
+	} `json:"keys"`
+}
+
+// LoadAPIKeyStore reads a key store file: named plans and the keys granted
+// to each client, see config/api_keys.example.json.
+func LoadAPIKeyStore(path string) (*APIKeyStore, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f apiKeyFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parse api keys %s: %w", path, err)
+	}
+	s := &APIKeyStore{clients: make(map[string]Client, len(f.Keys))}
+	for _, k := range f.Keys {
+		plan, ok := f.Plans[k.Plan]
+		if !ok {
+			return nil, fmt.Errorf("api key for client %q: unknown plan %q", k.Client, k.Plan)
+		}
+		digest := strings.ToLower(k.KeySHA256)
+		if len(digest) != sha256.Size*2 {
+			return nil, fmt.Errorf("api key for client %q: key_sha256 is not a sha256 hex digest", k.Client)
+		}
+		plan.Name = k.Plan
+		s.clients[digest] = Client{ID: k.Client, Plan: plan}
+	}
+	return s, nil
+}
+
+// Lookup returns the client owning key.
+func (s *APIKeyStore) Lookup(key string) (Client, bool) {
+	if s == nil || key == "" {
+		return Client{}, false
+	}
+	sum := sha256.Sum256([]byte(key))
+	c, ok := s.clients[hex.EncodeToString(sum[:])]
+	return c, ok
+}
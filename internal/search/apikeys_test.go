@@ -0,0 +1,30 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPIKeyStore(t *testing.T) {
+	store, err := LoadAPIKeyStore("../../config/api_keys.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := store.Lookup("demo-partner-key")
+	if !ok || c.ID != "demo-partner" || c.Plan.Name != "partner" || c.Plan.RequestsPerMinute != 600 || c.Plan.Burst != 100 {
+		t.Fatalf("unexpected client %+v (%v)", c, ok)
+	}
+	if _, ok := store.Lookup("5f7b2ed74a9736a538a6fb5a5a1a76afdcdd0d177d7fc6e79c6b6843527d848d"); ok {
+		t.Fatal("expected the digest itself not to be a valid key")
+	}
+	if _, ok := (*APIKeyStore)(nil).Lookup("demo-partner-key"); ok {
+		t.Fatal("expected a nil store to know no keys")
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(path, []byte(`{"plans":{},"keys":[{"key_sha256":"ab","client":"x","plan":"gold"}]}`), 0o644)
+	if _, err := LoadAPIKeyStore(path); err == nil {
+		t.Fatal("expected an error for an unknown plan")
+	}
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half-open"
+	case breakerOpen:
+		return "open"
+	}
+	return "closed"
+}
+
+// BreakerConfig controls when a provider's circuit opens and how it recovers.
+type BreakerConfig struct {
+	// FailureRatio opens the circuit once failures/requests in the current
+	// window reach it, provided at least MinRequests were seen.
+	FailureRatio float64
+	MinRequests  int
+	// ConsecutiveFailures opens the circuit regardless of the ratio.
+	ConsecutiveFailures int
+	// Window is how long closed-state counts are kept before resetting.
+	Window time.Duration
+	// CoolDown is how long the circuit stays open before a half-open probe.
+	CoolDown time.Duration
+}
+
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureRatio:        0.5,
+		MinRequests:         10,
+		ConsecutiveFailures: 5,
+		Window:              time.Minute,
+		CoolDown:            30 * time.Second,
+	}
+}
+
+// circuitBreaker tracks the health of one provider.
+type circuitBreaker struct {
+	mu          sync.Mutex
+	cfg         BreakerConfig
+	state       breakerState
+	requests    int
+	failures    int
+	consecutive int
+	windowStart time.Time
+	openedAt    time.Time
+	probing     bool
+	now         func() time.Time
+	onChange    func(breakerState)
+}
+
+func newCircuitBreaker(cfg BreakerConfig, onChange func(breakerState)) *circuitBreaker {
+	b := &circuitBreaker{cfg: cfg, now: time.Now, onChange: onChange}
+	b.windowStart = b.now()
+	return b
+}
+
+// allow reports whether a call may go through. In half-open state only a
+// single probe is let through until its outcome is recorded.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.cfg.CoolDown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	if b.cfg.Window > 0 && now.Sub(b.windowStart) >= b.cfg.Window {
+		b.requests, b.failures = 0, 0
+		b.windowStart = now
+	}
+	return true
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+
+	if b.state == breakerHalfOpen {
+		b.probing = false
+		if success {
+			b.reset(now)
+			b.setState(breakerClosed)
+		} else {
+			b.trip(now)
+		}
+		return
+	}
+	if b.state == breakerOpen {
+		// late result from a call started before the circuit opened
+		return
+	}
+
+	b.requests++
+	if success {
+		b.consecutive = 0
+		return
+	}
+	b.failures++
+	b.consecutive++
+
+	if b.cfg.ConsecutiveFailures > 0 && b.consecutive >= b.cfg.ConsecutiveFailures {
+		b.trip(now)
+		return
+	}
+	if b.cfg.FailureRatio > 0 && b.requests >= b.cfg.MinRequests &&
+		float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+		b.trip(now)
+	}
+}
+
+// abort releases a half-open probe whose outcome is unknown, e.g. because the
+// caller cancelled, so the next request can probe again.
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) current() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *circuitBreaker) trip(now time.Time) {
+	b.openedAt = now
+	b.reset(now)
+	b.setState(breakerOpen)
+}
+
+func (b *circuitBreaker) reset(now time.Time) {
+	b.requests, b.failures, b.consecutive = 0, 0, 0
+	b.windowStart = now
+}
+
+// setState must be called with mu held.
+func (b *circuitBreaker) setState(s breakerState) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	if b.onChange != nil {
+		b.onChange(s)
+	}
+}
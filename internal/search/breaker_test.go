@@ -0,0 +1,105 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(cfg BreakerConfig) (*circuitBreaker, *fakeClock) {
+	clk := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newCircuitBreaker(cfg, nil)
+	b.now = clk.Now
+	b.windowStart = clk.Now()
+	return b, clk
+}
+
+func TestCircuitBreaker_ConsecutiveFailuresOpen(t *testing.T) {
+	b, clk := newTestBreaker(BreakerConfig{ConsecutiveFailures: 3, CoolDown: 10 * time.Second})
+	for i := 0; i < 3; i++ {
+		if !b.allow() {
+			t.Fatalf("call %d should be allowed while closed", i)
+		}
+		b.record(false)
+	}
+	if b.current() != breakerOpen {
+		t.Fatalf("expected open, got %s", b.current())
+	}
+	if b.allow() {
+		t.Fatal("expected open circuit to reject calls")
+	}
+
+	clk.Advance(10 * time.Second)
+	if !b.allow() {
+		t.Fatal("expected a half-open probe after cool-down")
+	}
+	if b.allow() {
+		t.Fatal("expected only one probe while half-open")
+	}
+	b.record(true)
+	if b.current() != breakerClosed {
+		t.Fatalf("expected closed after successful probe, got %s", b.current())
+	}
+}
+
+func TestCircuitBreaker_FailedProbeReopens(t *testing.T) {
+	b, clk := newTestBreaker(BreakerConfig{ConsecutiveFailures: 1, CoolDown: time.Second})
+	b.allow()
+	b.record(false)
+
+	clk.Advance(time.Second)
+	if !b.allow() {
+		t.Fatal("expected probe")
+	}
+	b.record(false)
+	if b.current() != breakerOpen {
+		t.Fatalf("expected reopen after failed probe, got %s", b.current())
+	}
+	if b.allow() {
+		t.Fatal("expected a fresh cool-down after failed probe")
+	}
+}
+
+func TestCircuitBreaker_FailureRatio(t *testing.T) {
+	b, _ := newTestBreaker(BreakerConfig{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute, CoolDown: time.Second})
+	outcomes := []bool{true, false, true}
+	for _, ok := range outcomes {
+		b.allow()
+		b.record(ok)
+	}
+	if b.current() != breakerClosed {
+		t.Fatal("ratio must not trip before MinRequests")
+	}
+	b.allow()
+	b.record(false)
+	if b.current() != breakerOpen {
+		t.Fatalf("expected open at 2/4 failures, got %s", b.current())
+	}
+
+	// counts reset with the window
+	b2, clk2 := newTestBreaker(BreakerConfig{FailureRatio: 0.5, MinRequests: 2, Window: time.Minute, CoolDown: time.Second})
+	b2.allow()
+	b2.record(false)
+	clk2.Advance(time.Minute)
+	b2.allow()
+	b2.record(true)
+	if b2.current() != breakerClosed {
+		t.Fatal("expected window reset to forget the old failure")
+	}
+}
+
+func TestCircuitBreaker_AbortReleasesProbe(t *testing.T) {
+	b, clk := newTestBreaker(BreakerConfig{ConsecutiveFailures: 1, CoolDown: time.Second})
+	b.allow()
+	b.record(false)
+	clk.Advance(time.Second)
+	b.allow()
+	b.abort()
+	if !b.allow() {
+		t.Fatal("expected a new probe after abort")
+	}
+}
@@ -1,22 +1,84 @@
 package search
 
 import (
+	"container/list"
 	"context"
+	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/example/mini-hotel-aggregator/internal/obs"
 )
 
 type CacheService interface {
 	GetOrCompute(ctx context.Context, key string, fn func(ctx context.Context) (AggregatedResult, error)) (AggregatedResult, error)
+
+	// Admin operations. List and DeletePrefix match keys starting with prefix
+	// ("" matches everything); deletes report how many entries were removed.
+	List(ctx context.Context, prefix string) ([]CacheEntryInfo, error)
+	Get(ctx context.Context, key string) (CacheEntry, bool, error)
+	Delete(ctx context.Context, key string) (bool, error)
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+	Flush(ctx context.Context) (int, error)
+}
+
+// CacheEntryInfo describes a cached key for the admin API.
+type CacheEntryInfo struct {
+	Key   string `json:"key"`
+	AgeMs int64  `json:"age_ms"`
+	// TTLMs is how much longer the entry is fresh, negative once stale.
+	TTLMs int64 `json:"ttl_ms"`
+	Bytes int64 `json:"bytes,omitempty"`
+	// Error is set for negatively cached failures.
+	Error string `json:"error,omitempty"`
+}
+
+// CacheEntry is a cached key with its stored result.
+type CacheEntry struct {
+	CacheEntryInfo
+	Result *AggregatedResult `json:"result,omitempty"`
 }
 
+// Values reported in AggregatedResult.Stats.Cache.
+const (
+	CacheHit        = "hit"
+	CacheMiss       = "miss"
+	CacheStale      = "stale"
+	CacheStaleError = "stale-error"
+	// CacheCoalesced marks a request that waited on another request's
+	// computation instead of starting its own.
+	CacheCoalesced = "coalesced"
+)
+
+// Default bounds applied by NewCache unless overridden.
+const (
+	DefaultCacheMaxEntries = 10000
+	DefaultCacheMaxBytes   = 64 << 20
+)
+
+// Reasons reported in the cache_evictions_total metric.
+const (
+	evictExpired  = "expired"
+	evictCapacity = "capacity"
+)
+
 type cacheEntry struct {
-	val     AggregatedResult
-	expiry  time.Time
-	ready   bool
-	waiters []chan resultOrErr
+	key      string
+	val      AggregatedResult
+	storedAt time.Time
+	expiry   time.Time
+	ready    bool // val (or err, for a negative entry) holds a computed result
+	err      error
+	// computing is set while a computation (initial or background refresh)
+	// is in flight; waiters receive its outcome.
+	computing bool
+	waiters   []chan resultOrErr
+	warmed    bool // stored by the cache warmer
+	size      int64
+	elem      *list.Element
 }
 
 type resultOrErr struct {
@@ -25,33 +87,131 @@ type resultOrErr struct {
 }
 
 type cache struct {
-	mu      sync.Mutex
-	ttl     time.Duration
-	items   map[string]*cacheEntry
-	metrics *obs.Metrics
+	mu  sync.Mutex
+	ttl time.Duration
+	// staleWhileRevalidate is how long after ttl a stale value is still
+	// served while a single background refresh runs.
+	staleWhileRevalidate time.Duration
+	// staleIfError is how long after ttl the last good value is served when
+	// recomputation fails.
+	staleIfError time.Duration
+	// negativeTTL, when set, caches errors and empty results for this long
+	// instead of recomputing them on every request.
+	negativeTTL time.Duration
+	// degradedTTL, when set, caps the ttl of results missing some providers
+	// so the absent providers are retried sooner.
+	degradedTTL    time.Duration
+	refreshTimeout time.Duration
+	items          map[string]*cacheEntry
+	lru            *list.List // front is most recently used
+	maxEntries     int
+	maxBytes       int64
+	bytes          int64
+	metrics        *obs.Metrics
+	now            func() time.Time
+}
+
+// CacheOption configures optional cache behaviour.
+type CacheOption func(*cache)
+
+func WithStaleWhileRevalidate(d time.Duration) CacheOption {
+	return func(c *cache) { c.staleWhileRevalidate = d }
+}
+
+func WithStaleIfError(d time.Duration) CacheOption {
+	return func(c *cache) { c.staleIfError = d }
 }
 
-func NewCache(ttl time.Duration, m *obs.Metrics) *cache {
-	return &cache{ttl: ttl, items: make(map[string]*cacheEntry), metrics: m}
+// WithNegativeTTL caches failed computations and results without hotels for
+// d. Without it errors are never cached.
+func WithNegativeTTL(d time.Duration) CacheOption {
+	return func(c *cache) { c.negativeTTL = d }
+}
+
+// WithDegradedTTL stores results where a provider failed or was skipped for
+// at most d.
+func WithDegradedTTL(d time.Duration) CacheOption {
+	return func(c *cache) { c.degradedTTL = d }
+}
+
+// WithRefreshTimeout bounds background refreshes, which no longer have a
+// request deadline to inherit.
+func WithRefreshTimeout(d time.Duration) CacheOption {
+	return func(c *cache) { c.refreshTimeout = d }
+}
+
+// WithMaxEntries caps the number of cached keys; 0 disables the cap.
+func WithMaxEntries(n int) CacheOption {
+	return func(c *cache) { c.maxEntries = n }
+}
+
+// WithMaxBytes caps the estimated memory held by cached results; 0 disables
+// the cap.
+func WithMaxBytes(n int64) CacheOption {
+	return func(c *cache) { c.maxBytes = n }
+}
+
+func NewCache(ttl time.Duration, m *obs.Metrics, opts ...CacheOption) *cache {
+	c := &cache{
+		ttl:            ttl,
+		items:          make(map[string]*cacheEntry),
+		lru:            list.New(),
+		maxEntries:     DefaultCacheMaxEntries,
+		maxBytes:       DefaultCacheMaxBytes,
+		metrics:        m,
+		now:            time.Now,
+		refreshTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *cache) GetOrCompute(ctx context.Context, key string, fn func(ctx context.Context) (AggregatedResult, error)) (AggregatedResult, error) {
 	c.mu.Lock()
 	entry, found := c.items[key]
-	now := time.Now()
+	now := c.now()
+	if found {
+		c.lru.MoveToFront(entry.elem)
+	}
 
-	// If cached and fresh, return it
-	if found && entry.ready && now.Before(entry.expiry) {
-		val := entry.val
+	// the warmer recomputes entries about to expire so users keep finding
+	// them fresh, and leaves the others alone
+	margin, warming := warmMargin(ctx)
+	if warming && found && entry.ready && entry.err == nil && entry.expiry.Sub(now) > margin {
+		val := entry.view(CacheHit, now)
 		c.mu.Unlock()
-		if c.metrics != nil {
-			c.metrics.IncCacheHits()
-		}
 		return val, nil
 	}
 
+	if found && entry.ready && !warming {
+		// If cached and fresh, return it
+		if now.Before(entry.expiry) {
+			if err := entry.err; err != nil {
+				c.mu.Unlock()
+				return AggregatedResult{}, err
+			}
+			val, warmed := entry.view(CacheHit, now), entry.warmed
+			c.mu.Unlock()
+			c.observe(CacheHit, warmed)
+			return val, nil
+		}
+		// Soft-expired: serve stale now, revalidate once in the background
+		if entry.err == nil && now.Before(entry.expiry.Add(c.staleWhileRevalidate)) {
+			if !entry.computing {
+				entry.computing = true
+				go c.refresh(ctx, entry, fn)
+			}
+			val, warmed := entry.view(CacheStale, now), entry.warmed
+			c.mu.Unlock()
+			c.observe(CacheStale, warmed)
+			return val, nil
+		}
+	}
+
 	// Collapse: if computation in progress, join waiters
-	if found && !entry.ready {
+	if found && entry.computing {
 		ch := make(chan resultOrErr, 1)
 		entry.waiters = append(entry.waiters, ch)
 		c.mu.Unlock()
@@ -63,29 +223,306 @@ func (c *cache) GetOrCompute(ctx context.Context, key string, fn func(ctx contex
 		}
 	}
 
-	// Start new computation and mark as in-flight
-	ch := make(chan resultOrErr, 1)
-	entry = &cacheEntry{waiters: []chan resultOrErr{ch}}
-	c.items[key] = entry
+	// Start new computation and mark as in-flight. An expired entry is kept
+	// so its value can still be served if this computation fails.
+	if !found {
+		entry = &cacheEntry{key: key}
+		entry.elem = c.lru.PushFront(entry)
+		c.items[key] = entry
+		c.evictLocked(entry)
+		c.reportSizeLocked()
+	}
+	entry.computing = true
 	c.mu.Unlock()
 
 	// Actual computation (only one goroutine does this)
 	res, err := fn(ctx)
-	result := resultOrErr{res: res, err: err}
+	r := c.finish(entry, res, err, now, warming)
+	c.observe(r.res.Stats.Cache, false)
+	return r.res, r.err
+}
+
+// refresh recomputes a stale entry detached from the request that noticed it,
+// so the refresh survives that request finishing.
+func (c *cache) refresh(ctx context.Context, entry *cacheEntry, fn func(ctx context.Context) (AggregatedResult, error)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.refreshTimeout)
+	defer cancel()
+	start := c.now()
+	res, err := fn(ctx)
+	c.finish(entry, res, err, start, false)
+}
 
-	// Save result and notify waiters
+// finish stores the outcome of a computation started at start and notifies
+// waiters. A failure inside the stale-if-error window keeps and serves the
+// previous value instead; other failures are only stored as negative entries.
+func (c *cache) finish(entry *cacheEntry, res AggregatedResult, err error, start time.Time, warmed bool) resultOrErr {
 	c.mu.Lock()
-	entry.val = res
-	entry.expiry = now.Add(c.ttl)
-	entry.ready = true
+	now := c.now()
+	var result resultOrErr
+	switch {
+	case err != nil && entry.ready && entry.err == nil && now.Before(entry.expiry.Add(c.staleIfError)):
+		result = resultOrErr{res: entry.view(CacheStaleError, now)}
+	// A caller giving up or running out of time says nothing about the key,
+	// so it is not cached.
+	case err != nil && c.negativeTTL > 0 && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded):
+		c.storeLocked(entry, AggregatedResult{}, err, start, c.negativeTTL, warmed)
+		result = resultOrErr{err: err}
+	case err != nil:
+		// Nothing worth keeping: forget the placeholder so the next request
+		// recomputes. An older expired value is left for the janitor.
+		if !entry.ready {
+			c.deleteLocked(entry)
+		}
+		result = resultOrErr{err: err}
+	default:
+		ttl := resultTTL(res, c.ttl, c.degradedTTL, c.negativeTTL)
+		c.storeLocked(entry, res, nil, dataTime(res, start), ttl, warmed)
+		result = resultOrErr{res: entry.view(CacheMiss, now)}
+	}
+	// waiters share the outcome, a fresh result is reported to them as coalesced
+	shared := result
+	if result.err == nil && result.res.Stats.Cache == CacheMiss {
+		shared.res.Stats.Cache = CacheCoalesced
+	}
+	entry.computing = false
 	waiters := entry.waiters
 	entry.waiters = nil
+	c.evictLocked(entry)
+	c.reportSizeLocked()
 	c.mu.Unlock()
 
 	for _, w := range waiters {
-		w <- result
+		w <- shared
 		close(w)
 	}
+	return result
+}
+
+// resultTTL is how long res is kept: ttl, capped by degradedTTL when a
+// provider failed or was skipped, or negativeTTL when it has no hotels. A
+// zero degradedTTL or negativeTTL disables that rule.
+func resultTTL(res AggregatedResult, ttl, degradedTTL, negativeTTL time.Duration) time.Duration {
+	if degradedTTL > 0 && res.Stats.ProvidersFailed+res.Stats.ProvidersSkipped > 0 {
+		ttl = min(ttl, degradedTTL)
+	}
+	if emptyResult(res) && negativeTTL > 0 {
+		ttl = negativeTTL
+	}
+	return ttl
+}
+
+// emptyResult reports whether res is kept as briefly as an error.
+func emptyResult(res AggregatedResult) bool { return len(res.Hotels) == 0 }
+
+// dataTime is when the data in res was fetched: start, or earlier when res
+// reuses provider answers from an earlier search. The entry ages from then.
+func dataTime(res AggregatedResult, start time.Time) time.Time {
+	if !res.ComputedAt.IsZero() && res.ComputedAt.Before(start) {
+		return res.ComputedAt
+	}
+	return start
+}
 
-	return res, err
+func (c *cache) storeLocked(entry *cacheEntry, res AggregatedResult, err error, storedAt time.Time, ttl time.Duration, warmed bool) {
+	entry.val = res
+	entry.err = err
+	entry.warmed = warmed
+	entry.storedAt = storedAt
+	entry.expiry = storedAt.Add(ttl)
+	entry.ready = true
+	size := estimateSize(entry.key, res)
+	// an entry purged while it was computing still answers its waiters but
+	// no longer counts towards the cache
+	if c.items[entry.key] == entry {
+		c.bytes += size - entry.size
+	}
+	entry.size = size
+}
+
+func (c *cache) List(ctx context.Context, prefix string) ([]CacheEntryInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	out := make([]CacheEntryInfo, 0, len(c.items))
+	for _, e := range c.items {
+		if e.ready && strings.HasPrefix(e.key, prefix) {
+			out = append(out, e.info(now))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (c *cache) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok || !e.ready {
+		return CacheEntry{}, false, nil
+	}
+	entry := CacheEntry{CacheEntryInfo: e.info(c.now())}
+	if e.err == nil {
+		res := e.val
+		entry.Result = &res
+	}
+	return entry, true, nil
+}
+
+func (c *cache) Delete(ctx context.Context, key string) (bool, error) {
+	n, err := c.deleteWhere(func(k string) bool { return k == key })
+	return n > 0, err
+}
+
+func (c *cache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return c.deleteWhere(func(k string) bool { return strings.HasPrefix(k, prefix) })
+}
+
+func (c *cache) Flush(ctx context.Context) (int, error) {
+	return c.deleteWhere(func(string) bool { return true })
+}
+
+// deleteWhere purges matching keys, including ones still computing: results
+// computed before a purge are not stored.
+func (c *cache) deleteWhere(match func(key string) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k, e := range c.items {
+		if match(k) {
+			c.deleteLocked(e)
+			if e.ready {
+				n++
+			}
+		}
+	}
+	c.reportSizeLocked()
+	return n, nil
+}
+
+func (e *cacheEntry) info(now time.Time) CacheEntryInfo {
+	info := CacheEntryInfo{
+		Key:   e.key,
+		AgeMs: now.Sub(e.storedAt).Milliseconds(),
+		TTLMs: e.expiry.Sub(now).Milliseconds(),
+		Bytes: e.size,
+	}
+	if e.err != nil {
+		info.Error = e.err.Error()
+	}
+	return info
+}
+
+// Run sweeps out entries that can no longer be served every interval until
+// ctx is cancelled.
+func (c *cache) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.sweep()
+		}
+	}
+}
+
+// sweep removes entries past every stale window. In-flight entries are left
+// alone; their computation will store a fresh value.
+func (c *cache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for _, e := range c.items {
+		if e.ready && !e.computing && !now.Before(c.servableUntil(e)) {
+			c.removeLocked(e, evictExpired)
+		}
+	}
+	c.reportSizeLocked()
+}
+
+// servableUntil is when e can no longer be served, even stale.
+func (c *cache) servableUntil(e *cacheEntry) time.Time {
+	if e.err != nil {
+		return e.expiry // negative entries are never served stale
+	}
+	return e.expiry.Add(max(c.staleWhileRevalidate, c.staleIfError))
+}
+
+// evictLocked drops least recently used entries until the cache is within
+// its bounds. keep and in-flight entries are never evicted.
+func (c *cache) evictLocked(keep *cacheEntry) {
+	for el := c.lru.Back(); el != nil && c.overLimitLocked(); {
+		e := el.Value.(*cacheEntry)
+		el = el.Prev()
+		if e == keep || e.computing {
+			continue
+		}
+		c.removeLocked(e, evictCapacity)
+	}
+}
+
+func (c *cache) overLimitLocked() bool {
+	return (c.maxEntries > 0 && len(c.items) > c.maxEntries) ||
+		(c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
+
+func (c *cache) removeLocked(e *cacheEntry, reason string) {
+	c.deleteLocked(e)
+	if c.metrics != nil {
+		c.metrics.IncCacheEvictions(reason)
+	}
+}
+
+func (c *cache) deleteLocked(e *cacheEntry) {
+	if c.items[e.key] != e {
+		return
+	}
+	delete(c.items, e.key)
+	c.lru.Remove(e.elem)
+	c.bytes -= e.size
+}
+
+func (c *cache) reportSizeLocked() {
+	if c.metrics != nil {
+		c.metrics.SetCacheSize(len(c.items), c.bytes)
+	}
+}
+
+func (c *cache) observe(status string, warmed bool) {
+	if c.metrics == nil || status == CacheMiss {
+		return
+	}
+	c.metrics.IncCacheHits()
+	if warmed {
+		c.metrics.IncCacheWarmHits()
+	}
+}
+
+// view returns a copy of the cached value annotated with how it was served
+// and how old it is at now.
+func (e *cacheEntry) view(status string, now time.Time) AggregatedResult {
+	res := e.val
+	res.Stats.Cache = status
+	res.Stats.CacheAgeMs = now.Sub(e.storedAt).Milliseconds()
+	res.Stats.CacheTTLMs = e.expiry.Sub(now).Milliseconds()
+	return res
+}
+
+// estimateSize approximates the memory held by a cached result: struct sizes
+// plus string and slice contents. It only needs to be good enough to keep
+// the cache within its byte budget.
+func estimateSize(key string, res AggregatedResult) int64 {
+	n := int64(unsafe.Sizeof(cacheEntry{})) + int64(len(key)) +
+		int64(len(res.Currency)) + int64(len(res.Stats.Cache))
+	for _, h := range res.Hotels {
+		n += int64(unsafe.Sizeof(h)) + int64(len(h.HotelID)+len(h.Name)+len(h.City)+len(h.Currency)+len(h.Provider)+len(h.Board))
+		for _, a := range h.Amenities {
+			n += int64(unsafe.Sizeof(a)) + int64(len(a))
+		}
+		for _, o := range h.Offers {
+			n += int64(unsafe.Sizeof(o)) + int64(len(o.Provider)+len(o.HotelID)+len(o.Currency)+len(o.Board)+len(o.OriginalCurrency))
+		}
+	}
+	return n
 }
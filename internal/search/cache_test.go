@@ -2,11 +2,15 @@ package search
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/example/mini-hotel-aggregator/internal/models"
 	"github.com/example/mini-hotel-aggregator/internal/obs"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestCacheCollapse(t *testing.T) {
@@ -36,3 +40,393 @@ func TestCacheCollapse(t *testing.T) {
 		t.Fatalf("expected single compute got %d", calls)
 	}
 }
+
+func newTestCache(opts ...CacheOption) (*cache, *fakeClock) {
+	clk := &fakeClock{t: time.Unix(1700000000, 0)}
+	c := NewCache(10*time.Second, nil, opts...)
+	c.now = clk.Now
+	return c, clk
+}
+
+func TestCacheStatus_HitAndMiss(t *testing.T) {
+	c, clk := newTestCache()
+	fn := func(ctx context.Context) (AggregatedResult, error) { return AggregatedResult{}, nil }
+
+	res, _ := c.GetOrCompute(context.Background(), "k", fn)
+	if res.Stats.Cache != CacheMiss {
+		t.Fatalf("expected miss, got %q", res.Stats.Cache)
+	}
+	clk.Advance(5 * time.Second)
+	res, _ = c.GetOrCompute(context.Background(), "k", fn)
+	if res.Stats.Cache != CacheHit || res.Stats.CacheAgeMs != 5000 || res.Stats.CacheTTLMs != 5000 {
+		t.Fatalf("expected hit aged 5s with 5s left, got %+v", res.Stats)
+	}
+	clk.Advance(6 * time.Second)
+	res, _ = c.GetOrCompute(context.Background(), "k", fn)
+	if res.Stats.Cache != CacheMiss {
+		t.Fatalf("expected miss after ttl without swr, got %q", res.Stats.Cache)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	c, clk := newTestCache(WithStaleWhileRevalidate(time.Minute))
+	calls := 0
+	release := make(chan struct{})
+	refreshed := make(chan struct{})
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		calls++
+		if calls == 2 {
+			<-release
+			defer close(refreshed)
+		}
+		return AggregatedResult{Hotels: make([]Hotel, calls)}, nil
+	}
+
+	c.GetOrCompute(context.Background(), "k", fn)
+	clk.Advance(15 * time.Second)
+
+	// Both callers get the stale value immediately; only one refresh starts.
+	for i := 0; i < 2; i++ {
+		res, err := c.GetOrCompute(context.Background(), "k", fn)
+		if err != nil || res.Stats.Cache != CacheStale || len(res.Hotels) != 1 {
+			t.Fatalf("expected stale first result, got %q with %d hotels (%v)", res.Stats.Cache, len(res.Hotels), err)
+		}
+	}
+	close(release)
+	<-refreshed
+
+	// wait for the refresh to be stored
+	deadline := time.Now().Add(time.Second)
+	for {
+		res, _ := c.GetOrCompute(context.Background(), "k", fn)
+		if res.Stats.Cache == CacheHit {
+			if len(res.Hotels) != 2 {
+				t.Fatalf("expected refreshed result, got %d hotels", len(res.Hotels))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh was not stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a single background refresh, got %d computes", calls)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	c, clk := newTestCache(WithStaleIfError(time.Minute))
+	fail := false
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		if fail {
+			return AggregatedResult{}, errors.New("all providers down")
+		}
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+
+	c.GetOrCompute(context.Background(), "k", fn)
+	fail = true
+
+	clk.Advance(30 * time.Second)
+	res, err := c.GetOrCompute(context.Background(), "k", fn)
+	if err != nil || res.Stats.Cache != CacheStaleError || len(res.Hotels) != 1 {
+		t.Fatalf("expected last good result, got %q with %d hotels (%v)", res.Stats.Cache, len(res.Hotels), err)
+	}
+
+	// Past the stale-if-error window the failure surfaces.
+	clk.Advance(time.Minute)
+	if _, err := c.GetOrCompute(context.Background(), "k", fn); err == nil {
+		t.Fatal("expected error once the stale-if-error window has passed")
+	}
+}
+
+// outageProvider answers with one hotel until down is set.
+type outageProvider struct {
+	name string
+	down bool
+}
+
+func (p *outageProvider) Search(ctx context.Context, req *models.SearchRequest) ([]Hotel, error) {
+	if p.down {
+		return nil, errors.New("connection refused")
+	}
+	return []Hotel{{HotelID: p.name + "-1", Name: "A", Price: 100}}, nil
+}
+func (p *outageProvider) Name() string { return p.name }
+
+func TestCacheStaleIfError_ProviderOutage(t *testing.T) {
+	providers := []*outageProvider{{name: "p1"}, {name: "p2"}}
+	agg := NewAggregator([]Provider{providers[0], providers[1]}, time.Second, obs.NewMetrics(prometheus.NewRegistry()))
+	c, clk := newTestCache(WithStaleIfError(time.Minute), WithNegativeTTL(5*time.Second))
+	req := &models.SearchRequest{City: "c", Checkin: "2025-11-20", Nights: 1, Adults: 1}
+	fn := func(ctx context.Context) (AggregatedResult, error) { return agg.Search(ctx, req) }
+
+	if res, err := c.GetOrCompute(context.Background(), "k", fn); err != nil || len(res.Hotels) != 2 {
+		t.Fatalf("expected both providers' hotels, got %+v (%v)", res.Hotels, err)
+	}
+	for _, p := range providers {
+		p.down = true
+	}
+	clk.Advance(30 * time.Second)
+	res, err := c.GetOrCompute(context.Background(), "k", fn)
+	if err != nil || res.Stats.Cache != CacheStaleError || len(res.Hotels) != 2 {
+		t.Fatalf("expected the last good result during the outage, got %q with %d hotels (%v)", res.Stats.Cache, len(res.Hotels), err)
+	}
+
+	clk.Advance(time.Minute)
+	if _, err := c.GetOrCompute(context.Background(), "k", fn); !errors.Is(err, ErrAllProvidersFailed) {
+		t.Fatalf("expected ErrAllProvidersFailed past the stale-if-error window, got %v", err)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	c := NewCache(time.Minute, m, WithMaxEntries(2))
+	fn := func(ctx context.Context) (AggregatedResult, error) { return AggregatedResult{}, nil }
+	ctx := context.Background()
+
+	c.GetOrCompute(ctx, "a", fn)
+	c.GetOrCompute(ctx, "b", fn)
+	c.GetOrCompute(ctx, "a", fn) // a is now more recent than b
+	c.GetOrCompute(ctx, "c", fn)
+
+	if _, ok := c.items["b"]; ok {
+		t.Fatal("expected least recently used key to be evicted")
+	}
+	if _, ok := c.items["a"]; !ok {
+		t.Fatal("expected recently used key to survive")
+	}
+	if got := testutil.ToFloat64(m.CacheEvictions.WithLabelValues(evictCapacity)); got != 1 {
+		t.Fatalf("expected 1 capacity eviction, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.CacheEntries); got != 2 {
+		t.Fatalf("expected entries gauge 2, got %v", got)
+	}
+}
+
+func TestCacheMaxBytes(t *testing.T) {
+	big := AggregatedResult{Hotels: make([]Hotel, 50)}
+	limit := estimateSize("k0", big) * 3
+	c := NewCache(time.Minute, nil, WithMaxBytes(limit))
+	fn := func(ctx context.Context) (AggregatedResult, error) { return big, nil }
+
+	for i := 0; i < 10; i++ {
+		c.GetOrCompute(context.Background(), fmt.Sprintf("k%d", i), fn)
+	}
+	if c.bytes > limit {
+		t.Fatalf("cache holds %d bytes, limit %d", c.bytes, limit)
+	}
+	if len(c.items) != 3 || c.lru.Len() != 3 {
+		t.Fatalf("expected 3 entries within the byte budget, got %d", len(c.items))
+	}
+}
+
+func TestCacheSweepRemovesExpired(t *testing.T) {
+	c, clk := newTestCache(WithStaleIfError(time.Minute))
+	fn := func(ctx context.Context) (AggregatedResult, error) { return AggregatedResult{}, nil }
+	c.GetOrCompute(context.Background(), "old", fn)
+	clk.Advance(65 * time.Second)
+	c.GetOrCompute(context.Background(), "new", fn)
+
+	// old is past ttl but still inside the stale-if-error window
+	c.sweep()
+	if len(c.items) != 2 {
+		t.Fatalf("expected entries inside a stale window to survive, got %d", len(c.items))
+	}
+	clk.Advance(10 * time.Second)
+	c.sweep()
+	if _, ok := c.items["old"]; ok || len(c.items) != 1 || c.bytes != c.items["new"].size {
+		t.Fatalf("expected only the fresh entry after sweep, got %d entries, %d bytes", len(c.items), c.bytes)
+	}
+}
+
+func TestCacheDoesNotStoreErrors(t *testing.T) {
+	c, _ := newTestCache()
+	calls := 0
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		calls++
+		if calls == 1 {
+			return AggregatedResult{}, errors.New("fan-out failed")
+		}
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+
+	if _, err := c.GetOrCompute(context.Background(), "k", fn); err == nil {
+		t.Fatal("expected the computation error")
+	}
+	if _, ok := c.items["k"]; ok {
+		t.Fatal("failed computation must not leave an entry behind")
+	}
+	res, err := c.GetOrCompute(context.Background(), "k", fn)
+	if err != nil || res.Stats.Cache != CacheMiss || len(res.Hotels) != 1 {
+		t.Fatalf("expected a fresh computation, got %q with %d hotels (%v)", res.Stats.Cache, len(res.Hotels), err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 computes, got %d", calls)
+	}
+}
+
+func TestCacheNegativeTTL(t *testing.T) {
+	c, clk := newTestCache(WithNegativeTTL(2 * time.Second))
+	calls := 0
+	boom := errors.New("fan-out failed")
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		calls++
+		return AggregatedResult{}, boom
+	}
+
+	c.GetOrCompute(context.Background(), "err", fn)
+	if _, err := c.GetOrCompute(context.Background(), "err", fn); !errors.Is(err, boom) {
+		t.Fatalf("expected cached error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected negative entry to be served, got %d computes", calls)
+	}
+	clk.Advance(3 * time.Second)
+	c.GetOrCompute(context.Background(), "err", fn)
+	if calls != 2 {
+		t.Fatalf("expected recompute after negative ttl, got %d computes", calls)
+	}
+
+	// Empty results use the negative ttl rather than the full ttl.
+	empty := func(ctx context.Context) (AggregatedResult, error) { calls++; return AggregatedResult{}, nil }
+	c.GetOrCompute(context.Background(), "empty", empty)
+	clk.Advance(3 * time.Second)
+	if res, _ := c.GetOrCompute(context.Background(), "empty", empty); res.Stats.Cache != CacheMiss {
+		t.Fatalf("expected empty result to expire after negative ttl, got %q", res.Stats.Cache)
+	}
+
+	// Cancelled callers are never negatively cached.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.GetOrCompute(ctx, "cancelled", func(ctx context.Context) (AggregatedResult, error) { return AggregatedResult{}, ctx.Err() })
+	if _, ok := c.items["cancelled"]; ok {
+		t.Fatal("expected cancellation not to be cached")
+	}
+
+	// Neither are callers whose deadline ran out.
+	c.GetOrCompute(context.Background(), "deadline", func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{}, fmt.Errorf("search: %w", context.DeadlineExceeded)
+	})
+	if _, ok := c.items["deadline"]; ok {
+		t.Fatal("expected an expired deadline not to be cached")
+	}
+}
+
+func TestCacheStatus_Coalesced(t *testing.T) {
+	c := NewCache(time.Minute, nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		close(started)
+		<-release
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+
+	leader := make(chan AggregatedResult)
+	go func() {
+		res, _ := c.GetOrCompute(context.Background(), "k", fn)
+		leader <- res
+	}()
+	<-started
+
+	waiter := make(chan AggregatedResult)
+	go func() {
+		res, _ := c.GetOrCompute(context.Background(), "k", fn)
+		waiter <- res
+	}()
+	// wait for the second caller to join before releasing the computation
+	for {
+		c.mu.Lock()
+		n := len(c.items["k"].waiters)
+		c.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if res := <-leader; res.Stats.Cache != CacheMiss {
+		t.Fatalf("expected leader to report miss, got %q", res.Stats.Cache)
+	}
+	if res := <-waiter; res.Stats.Cache != CacheCoalesced || len(res.Hotels) != 1 {
+		t.Fatalf("expected waiter to report coalesced, got %q", res.Stats.Cache)
+	}
+}
+
+func TestCacheAdminOperations(t *testing.T) {
+	c, clk := newTestCache()
+	ctx := context.Background()
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+	for _, k := range []string{"paris|a", "paris|b", "rome|a"} {
+		c.GetOrCompute(ctx, k, fn)
+	}
+	clk.Advance(4 * time.Second)
+
+	entries, _ := c.List(ctx, "paris|")
+	if len(entries) != 2 || entries[0].Key != "paris|a" || entries[0].AgeMs != 4000 || entries[0].TTLMs != 6000 || entries[0].Bytes == 0 {
+		t.Fatalf("unexpected listing %+v", entries)
+	}
+	entry, ok, _ := c.Get(ctx, "rome|a")
+	if !ok || entry.Result == nil || len(entry.Result.Hotels) != 1 {
+		t.Fatalf("expected rome entry with its result, got %+v", entry)
+	}
+
+	if ok, _ := c.Delete(ctx, "rome|a"); !ok {
+		t.Fatal("expected rome entry to be deleted")
+	}
+	if ok, _ := c.Delete(ctx, "rome|a"); ok {
+		t.Fatal("expected second delete to find nothing")
+	}
+	if n, _ := c.DeletePrefix(ctx, "paris|"); n != 2 {
+		t.Fatalf("expected 2 paris entries purged, got %d", n)
+	}
+	if len(c.items) != 0 || c.bytes != 0 || c.lru.Len() != 0 {
+		t.Fatalf("expected empty cache, got %d entries, %d bytes", len(c.items), c.bytes)
+	}
+}
+
+func TestCacheFlushDuringComputation(t *testing.T) {
+	c := NewCache(time.Minute, nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		c.GetOrCompute(context.Background(), "k", func(ctx context.Context) (AggregatedResult, error) {
+			close(started)
+			<-release
+			return AggregatedResult{Hotels: []Hotel{{HotelID: "old"}}}, nil
+		})
+		close(done)
+	}()
+	<-started
+	c.Flush(context.Background())
+	close(release)
+	<-done
+
+	// a result computed before the purge must not be served afterwards
+	if _, ok := c.items["k"]; ok || c.bytes != 0 {
+		t.Fatalf("expected purged computation not to be stored, bytes=%d", c.bytes)
+	}
+}
+
+func TestCacheDegradedTTL(t *testing.T) {
+	c, clk := newTestCache(WithDegradedTTL(2 * time.Second))
+	degraded := func(ctx context.Context) (AggregatedResult, error) {
+		res := AggregatedResult{Hotels: []Hotel{{HotelID: "h1", Price: 100}}}
+		res.Stats.ProvidersFailed = 1
+		return res, nil
+	}
+	res, _ := c.GetOrCompute(context.Background(), "k", degraded)
+	if res.Stats.CacheTTLMs != 2000 {
+		t.Fatalf("expected degraded result stored for 2s, got ttl %dms", res.Stats.CacheTTLMs)
+	}
+	clk.Advance(3 * time.Second)
+	if res, _ := c.GetOrCompute(context.Background(), "k", degraded); res.Stats.Cache != CacheMiss {
+		t.Fatalf("expected recompute after degraded ttl, got %q", res.Stats.Cache)
+	}
+}
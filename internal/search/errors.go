@@ -0,0 +1,33 @@
+package search
+
+import "errors"
+
+// ProviderError lets providers say whether a failure is worth retrying.
+// Errors that are not a ProviderError are treated as permanent.
+type ProviderError struct {
+	Retryable bool
+	// StatusCode is the upstream HTTP status, if any.
+	StatusCode int
+	Err        error
+}
+
+func (e *ProviderError) Error() string { return e.Err.Error() }
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// Retryable marks err as transient (timeouts, 5xx, throttling).
+func Retryable(err error) error {
+	return &ProviderError{Retryable: true, Err: err}
+}
+
+// Permanent marks err as not worth retrying (bad request, auth, bad payload).
+func Permanent(err error) error {
+	return &ProviderError{Retryable: false, Err: err}
+}
+
+func IsRetryable(err error) bool {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe.Retryable
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package search
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+)
+
+// ApplyFilters returns the hotels matching f. The input slice is not modified,
+// so it is safe to call on a cached result.
+func ApplyFilters(hotels []Hotel, f models.SearchFilters) []Hotel {
+	if f.MinPrice == 0 && f.MaxPrice == 0 && len(f.Stars) == 0 && len(f.Amenities) == 0 && f.NameContains == "" {
+		return hotels
+	}
+	name := strings.ToLower(f.NameContains)
+	out := make([]Hotel, 0, len(hotels))
+	for _, h := range hotels {
+		if f.MinPrice > 0 && h.Price < f.MinPrice {
+			continue
+		}
+		if f.MaxPrice > 0 && h.Price > f.MaxPrice {
+			continue
+		}
+		if len(f.Stars) > 0 && !slices.Contains(f.Stars, h.Stars) {
+			continue
+		}
+		if !hasAmenities(h, f.Amenities) {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(h.Name), name) {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// hasAmenities reports whether h offers every wanted amenity.
+func hasAmenities(h Hotel, wanted []string) bool {
+	for _, w := range wanted {
+		if !slices.Contains(h.Amenities, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+)
+
+func TestApplyFilters(t *testing.T) {
+	hotels := []Hotel{
+		{HotelID: "H1", Name: "Hotel Atlas", Price: 120, Stars: 4, Amenities: []string{"wifi", "pool"}},
+		{HotelID: "H2", Name: "Riad Sunset", Price: 90, Stars: 3, Amenities: []string{"wifi"}},
+		{HotelID: "H3", Name: "Kasbah Pearl", Price: 200, Stars: 5, Amenities: []string{"wifi", "pool", "spa"}},
+	}
+	tests := []struct {
+		name    string
+		filters models.SearchFilters
+		want    []string
+	}{
+		{"NoFilters", models.SearchFilters{}, []string{"H1", "H2", "H3"}},
+		{"MinPrice", models.SearchFilters{MinPrice: 100}, []string{"H1", "H3"}},
+		{"MaxPrice", models.SearchFilters{MaxPrice: 120}, []string{"H1", "H2"}},
+		{"PriceRange", models.SearchFilters{MinPrice: 100, MaxPrice: 150}, []string{"H1"}},
+		{"Stars", models.SearchFilters{Stars: []int{3, 5}}, []string{"H2", "H3"}},
+		{"Amenities", models.SearchFilters{Amenities: []string{"pool", "spa"}}, []string{"H3"}},
+		{"NameContains", models.SearchFilters{NameContains: "RIAD"}, []string{"H2"}},
+		{"Combined", models.SearchFilters{MaxPrice: 150, Amenities: []string{"wifi"}, Stars: []int{4}}, []string{"H1"}},
+		{"NoMatch", models.SearchFilters{NameContains: "ritz"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyFilters(hotels, tt.filters)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %+v", tt.want, got)
+			}
+			for i, id := range tt.want {
+				if got[i].HotelID != id {
+					t.Fatalf("expected %v, got %+v", tt.want, got)
+				}
+			}
+		})
+	}
+	if len(hotels) != 3 || hotels[0].HotelID != "H1" {
+		t.Fatal("input slice was modified")
+	}
+}
@@ -0,0 +1,100 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// HedgeConfig controls hedged provider calls: when a provider has not answered
+// within its observed latency quantile, a second identical call is fired and
+// whichever answers first wins.
+type HedgeConfig struct {
+	// Quantile of provider_latency_ms used as the hedge delay, e.g. 0.9.
+	Quantile float64
+	// MinSamples is how many observations a provider needs before hedging.
+	MinSamples uint64
+	// MinDelay is a floor on the hedge delay so fast providers are not
+	// called twice on every jitter.
+	MinDelay time.Duration
+}
+
+func DefaultHedgeConfig() HedgeConfig {
+	return HedgeConfig{Quantile: 0.9, MinSamples: 50, MinDelay: 20 * time.Millisecond}
+}
+
+// hedgeDelay returns how long to wait before hedging a call to provider, and
+// false while there is not enough latency data to decide.
+func (a *aggregator) hedgeDelay(provider string) (time.Duration, bool) {
+	ms, samples := a.metrics.ProviderLatencyQuantile(provider, a.hedge.Quantile)
+	if samples < a.hedge.MinSamples {
+		return 0, false
+	}
+	d := time.Duration(ms * float64(time.Millisecond))
+	if d < a.hedge.MinDelay {
+		d = a.hedge.MinDelay
+	}
+	return d, true
+}
+
+// errHedgeLost is the cancellation cause of the call that lost a hedge race.
+var errHedgeLost = errors.New("hedge lost")
+
+// hedgeLost reports whether ctx was cancelled because another call won.
+func hedgeLost(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errHedgeLost)
+}
+
+// hedged wraps attempt so that a slow call is raced against a second one.
+// The first success wins and the other call is cancelled; an error is only
+// returned once every call in flight has failed.
+func (a *aggregator) hedged(provider string, attempt func(ctx context.Context) ([]Hotel, error)) func(ctx context.Context) ([]Hotel, error) {
+	return func(ctx context.Context) ([]Hotel, error) {
+		delay, ok := a.hedgeDelay(provider)
+		if !ok {
+			return attempt(ctx)
+		}
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(errHedgeLost) // cancels the loser
+
+		type outcome struct {
+			hotels []Hotel
+			err    error
+			hedge  bool
+		}
+		ch := make(chan outcome, 2)
+		run := func(hedge bool) {
+			hs, err := attempt(ctx)
+			ch <- outcome{hotels: hs, err: err, hedge: hedge}
+		}
+
+		go run(false)
+		inflight := 1
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		var firstErr error
+		for {
+			select {
+			case <-timer.C:
+				a.metrics.IncProviderHedge(provider)
+				inflight++
+				go run(true)
+			case o := <-ch:
+				inflight--
+				if o.err == nil {
+					if o.hedge {
+						a.metrics.IncProviderHedgeWin(provider)
+					}
+					return o.hotels, nil
+				}
+				if firstErr == nil {
+					firstErr = o.err
+				}
+				if inflight == 0 {
+					return nil, firstErr
+				}
+			}
+		}
+	}
+}
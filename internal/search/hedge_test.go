@@ -0,0 +1,100 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// stallingProvider blocks its first call until cancelled and answers the
+// following ones immediately.
+type stallingProvider struct {
+	mu        sync.Mutex
+	calls     int
+	cancelled chan struct{}
+}
+
+func (s *stallingProvider) Search(ctx context.Context, req *models.SearchRequest) ([]Hotel, error) {
+	s.mu.Lock()
+	s.calls++
+	first := s.calls == 1
+	s.mu.Unlock()
+	if first {
+		<-ctx.Done()
+		close(s.cancelled)
+		return nil, ctx.Err()
+	}
+	return []Hotel{{HotelID: "H1", Name: "A", Price: 100}}, nil
+}
+func (s *stallingProvider) Name() string { return "stall" }
+
+func primeLatency(m *obs.Metrics, provider string, ms float64, n int) {
+	for i := 0; i < n; i++ {
+		m.ObserveProviderLatency(provider, ms)
+	}
+}
+
+func TestAggregator_HedgesSlowProvider(t *testing.T) {
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	primeLatency(m, "stall", 10, 100)
+	p := &stallingProvider{cancelled: make(chan struct{})}
+	cfg := HedgeConfig{Quantile: 0.9, MinSamples: 50, MinDelay: time.Millisecond}
+	agg := NewAggregator([]Provider{p}, time.Second, m, WithHedging(cfg))
+
+	start := time.Now()
+	res, err := agg.Search(context.Background(), &models.SearchRequest{City: "c", Checkin: "2025-11-20", Nights: 1, Adults: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("hedge did not cut tail latency: %v", time.Since(start))
+	}
+	if res.Stats.ProvidersSucceeded != 1 || len(res.Hotels) != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	select {
+	case <-p.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("losing call was not cancelled")
+	}
+	if got := testutil.ToFloat64(m.ProviderHedges.WithLabelValues("stall")); got != 1 {
+		t.Fatalf("expected 1 hedge, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.ProviderHedgeWins.WithLabelValues("stall")); got != 1 {
+		t.Fatalf("expected 1 hedge win, got %v", got)
+	}
+	// only the winner is observed, the cancelled loser returns right after
+	time.Sleep(20 * time.Millisecond)
+	if _, n := m.ProviderLatencyQuantile("stall", 0.9); n != 101 {
+		t.Fatalf("expected the loser's latency to be left out, got %d samples", n)
+	}
+}
+
+func TestAggregator_HedgeDelay(t *testing.T) {
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	agg := NewAggregator(nil, time.Second, m, WithHedging(HedgeConfig{Quantile: 0.9, MinSamples: 10, MinDelay: time.Millisecond}))
+
+	if _, ok := agg.hedgeDelay("p"); ok {
+		t.Fatal("expected no hedging without latency data")
+	}
+	// reading must not create an empty series for the provider
+	if n := testutil.CollectAndCount(m.ProviderLatency); n != 0 {
+		t.Fatalf("expected no latency series before observations, got %d", n)
+	}
+	// 90 fast calls (~10ms) and 10 slow ones (~200ms)
+	primeLatency(m, "p", 10, 90)
+	primeLatency(m, "p", 200, 10)
+	d, ok := agg.hedgeDelay("p")
+	if !ok {
+		t.Fatal("expected hedging once enough samples exist")
+	}
+	if d < 5*time.Millisecond || d > 25*time.Millisecond {
+		t.Fatalf("expected p90 delay near 10ms, got %v", d)
+	}
+}
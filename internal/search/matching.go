@@ -0,0 +1,169 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// HotelResolver maps provider hotel IDs to canonical hotels. Explicit mappings
+// win; unmapped hotels fall back to fuzzy matching on normalized name within
+// the same city.
+type HotelResolver struct {
+	// provider -> provider hotel id -> canonical id
+	byProvider map[string]map[string]string
+	// minimum token similarity (0..1) for a fuzzy match
+	threshold float64
+}
+
+// HotelMappings is the on-disk mapping table: canonical id -> provider ->
+// provider hotel id, e.g. {"C-ATLAS": {"mock1": "H123", "mock2": "ATL-7"}}.
+type HotelMappings map[string]map[string]string
+
+func LoadHotelMappings(path string) (HotelMappings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m HotelMappings
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse hotel mappings %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func NewHotelResolver(mappings HotelMappings, threshold float64) *HotelResolver {
+	r := &HotelResolver{byProvider: map[string]map[string]string{}, threshold: threshold}
+	for canonical, ids := range mappings {
+		for provider, id := range ids {
+			if r.byProvider[provider] == nil {
+				r.byProvider[provider] = map[string]string{}
+			}
+			r.byProvider[provider][strings.TrimSpace(id)] = canonical
+		}
+	}
+	return r
+}
+
+func (r *HotelResolver) lookup(provider, id string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	c, ok := r.byProvider[provider][id]
+	return c, ok
+}
+
+// matchSession clusters the hotels of a single search. Without a resolver it
+// only merges identical hotel IDs. Callers feed it providers in a fixed order
+// so identical searches cluster identically.
+type matchSession struct {
+	resolver *HotelResolver
+	// provider|provider hotel id -> canonical id already assigned in this search
+	aliases map[string]string
+	// city -> known clusters for fuzzy matching
+	clusters map[string][]nameCluster
+}
+
+type nameCluster struct {
+	id     string
+	tokens []string
+	// providers with an offer in the cluster; a provider never lists the
+	// same property twice, so they are not matched into it again
+	providers map[string]bool
+}
+
+func newMatchSession(r *HotelResolver) *matchSession {
+	return &matchSession{resolver: r, aliases: map[string]string{}, clusters: map[string][]nameCluster{}}
+}
+
+// canonicalID returns the id h should be merged under.
+func (s *matchSession) canonicalID(provider string, h Hotel) string {
+	key := provider + "|" + h.HotelID
+	if c, ok := s.aliases[key]; ok {
+		return c
+	}
+	c, ok := s.resolver.lookup(provider, h.HotelID)
+	if !ok {
+		c, ok = s.fuzzyMatch(provider, h)
+	}
+	if !ok {
+		c = h.HotelID
+	}
+	s.aliases[key] = c
+	s.remember(c, provider, h)
+	return c
+}
+
+// fuzzyMatch returns the first cluster in h's city whose name is similar
+// enough and that holds no offer from provider yet.
+func (s *matchSession) fuzzyMatch(provider string, h Hotel) (string, bool) {
+	if s.resolver == nil || s.resolver.threshold <= 0 || h.City == "" {
+		return "", false
+	}
+	tokens := nameTokens(h.Name)
+	for _, cl := range s.clusters[h.City] {
+		if !cl.providers[provider] && tokenSimilarity(tokens, cl.tokens) >= s.resolver.threshold {
+			return cl.id, true
+		}
+	}
+	return "", false
+}
+
+func (s *matchSession) remember(id, provider string, h Hotel) {
+	if s.resolver == nil || h.City == "" {
+		return
+	}
+	for _, cl := range s.clusters[h.City] {
+		if cl.id == id {
+			cl.providers[provider] = true
+			return
+		}
+	}
+	s.clusters[h.City] = append(s.clusters[h.City], nameCluster{id: id, tokens: nameTokens(h.Name), providers: map[string]bool{provider: true}})
+}
+
+// words that carry no identity, "Hotel Atlas" and "Atlas" are the same place
+var nameStopwords = map[string]bool{"hotel": true, "the": true, "and": true, "by": true}
+
+// nameTokens lowercases, drops punctuation and stopwords and returns the
+// remaining words sorted and de-duplicated.
+func nameTokens(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := map[string]bool{}
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		if nameStopwords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// tokenSimilarity is the Jaccard index of two sorted token sets.
+func tokenSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	i, j, common := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			common++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
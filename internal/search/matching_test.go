@@ -0,0 +1,139 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestAggregator_ResolvesHotelsAcrossProviders(t *testing.T) {
+	providers := []Provider{
+		&staticProvider{"mock1", []Hotel{
+			{HotelID: "H123", Name: "Hotel Atlas", City: "Marrakesh", Price: 130},
+			{HotelID: "H234", Name: "Riad Sunset", City: "Marrakesh", Price: 100},
+		}},
+		&staticProvider{"mock2", []Hotel{
+			{HotelID: "ATL-7", Name: "Atlas Hotel & Spa", City: "marrakesh", Price: 120}, // mapped
+			{HotelID: "RS-1", Name: "The Riad Sunset", City: "marrakesh", Price: 95},     // fuzzy
+			{HotelID: "RS-2", Name: "Riad Sunset", City: "fes", Price: 80},               // other city
+		}},
+	}
+	mappings := HotelMappings{"C-ATLAS": {"mock1": "H123", "mock2": "ATL-7"}}
+	agg := NewAggregator(providers, time.Second, obs.NewMetrics(prometheus.NewRegistry()),
+		WithHotelResolver(NewHotelResolver(mappings, 0.75)))
+
+	res, err := agg.Search(context.Background(), &models.SearchRequest{City: "marrakesh", Checkin: "2025-11-20", Nights: 1, Adults: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hotels) != 3 {
+		t.Fatalf("expected 3 hotels, got %+v", res.Hotels)
+	}
+	byID := map[string]Hotel{}
+	for _, h := range res.Hotels {
+		byID[h.HotelID] = h
+	}
+	atlas, ok := byID["C-ATLAS"]
+	if !ok || len(atlas.Offers) != 2 || atlas.Price != 120 {
+		t.Fatalf("expected mapped hotel with 2 offers, got %+v", atlas)
+	}
+	if atlas.Offers[0].HotelID != "ATL-7" || atlas.Offers[1].HotelID != "H123" {
+		t.Fatalf("provider hotel ids not kept on offers: %+v", atlas.Offers)
+	}
+	var riad Hotel
+	for _, h := range res.Hotels {
+		if h.City == "marrakesh" && h.HotelID != "C-ATLAS" {
+			riad = h
+		}
+	}
+	if len(riad.Offers) != 2 || riad.Price != 95 {
+		t.Fatalf("expected fuzzy-matched hotel with 2 offers, got %+v", riad)
+	}
+}
+
+func TestAggregator_WithoutResolverOnlyMergesIdenticalIDs(t *testing.T) {
+	providers := []Provider{
+		&staticProvider{"p1", []Hotel{{HotelID: "A", Name: "Riad Sunset", City: "fes", Price: 100}}},
+		&staticProvider{"p2", []Hotel{{HotelID: "B", Name: "Riad Sunset", City: "fes", Price: 90}}},
+	}
+	agg := NewAggregator(providers, time.Second, obs.NewMetrics(prometheus.NewRegistry()))
+	res, _ := agg.Search(context.Background(), &models.SearchRequest{City: "fes", Checkin: "2025-11-20", Nights: 1, Adults: 1})
+	if len(res.Hotels) != 2 {
+		t.Fatalf("expected 2 hotels, got %d", len(res.Hotels))
+	}
+}
+
+func TestAggregator_MatchingIsDeterministic(t *testing.T) {
+	providers := []Provider{
+		&staticProvider{"mock3", []Hotel{{HotelID: "K9", Name: "Hotel Atlas", City: "fes", Price: 70}}},
+		&staticProvider{"mock2", []Hotel{{HotelID: "K9", Name: "The Riad Sunset", City: "fes", Price: 90}}},
+		&staticProvider{"mock1", []Hotel{
+			{HotelID: "R1", Name: "Riad Sunset", City: "fes", Price: 100},
+			{HotelID: "R2", Name: "Riad Sunset", City: "fes", Price: 110}, // same name, another listing
+		}},
+	}
+	agg := NewAggregator(providers, time.Second, obs.NewMetrics(prometheus.NewRegistry()),
+		WithHotelResolver(NewHotelResolver(nil, 0.75)))
+	req := &models.SearchRequest{City: "fes", Checkin: "2025-11-20", Nights: 1, Adults: 1}
+
+	for i := 0; i < 20; i++ {
+		res, err := agg.Search(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offers := map[string]int{}
+		for _, h := range res.Hotels {
+			offers[h.HotelID] = len(h.Offers)
+		}
+		// mock2's K9 joins mock1's R1, mock3's unrelated K9 stays apart and
+		// R2 is not absorbed into a cluster mock1 already has an offer in
+		want := map[string]int{"R1": 2, "R2": 1, "K9": 1}
+		if len(offers) != len(want) {
+			t.Fatalf("run %d: expected hotels %v, got %v", i, want, offers)
+		}
+		for id, n := range want {
+			if offers[id] != n {
+				t.Fatalf("run %d: expected hotels %v, got %v", i, want, offers)
+			}
+		}
+	}
+}
+
+func TestTokenSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Hotel Atlas", "Atlas Hotel", 1},
+		{"The Riad Sunset", "riad-sunset", 1},
+		{"Riad Sunset", "Riad Sunset Medina", 2.0 / 3.0},
+		{"Kasbah Pearl", "Hotel Atlas", 0},
+		{"Hotel", "Hotel", 0},
+	}
+	for _, tt := range tests {
+		if got := tokenSimilarity(nameTokens(tt.a), nameTokens(tt.b)); got != tt.want {
+			t.Errorf("%q vs %q: expected %v, got %v", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
+
+func TestLoadHotelMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mappings.json")
+	if err := os.WriteFile(path, []byte(`{"C1":{"mock1":"H1","mock2":"X9"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadHotelMappings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewHotelResolver(m, 0.8)
+	if c, ok := r.lookup("mock2", "X9"); !ok || c != "C1" {
+		t.Fatalf("expected X9 to resolve to C1, got %q", c)
+	}
+}
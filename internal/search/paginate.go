@@ -0,0 +1,161 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid or expired cursor")
+
+// PageInfo describes the slice of results in a paged response.
+type PageInfo struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// pageCursor is the decoded form of the opaque cursor handed to clients. It
+// pins the ordered result list it was issued against (Generation) and the
+// query it belongs to, so it stays valid as long as recomputations of the
+// cached result list the same hotels in the same order.
+type pageCursor struct {
+	Offset     int    `json:"o"`
+	Generation int64  `json:"g"`
+	Query      string `json:"q"`
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Offset < 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// queryFingerprint identifies everything that determines the ordered result
+// list, so a cursor cannot be replayed against a different query.
+func queryFingerprint(cacheKey string, req *models.SearchRequest) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%+v", cacheKey, req.Page.Sort, req.Filters)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// resultGeneration identifies an ordered hotel list. Only the order matters
+// to offsets, so refreshed prices on the same hotels keep cursors valid.
+func resultGeneration(hotels []Hotel) int64 {
+	h := sha256.New()
+	for _, ht := range hotels {
+		h.Write([]byte(ht.HotelID))
+		h.Write([]byte{0})
+	}
+	return int64(binary.BigEndian.Uint64(h.Sum(nil)))
+}
+
+// SortHotels returns a sorted copy of hotels. Every order falls back to
+// HotelID so pages are stable across calls.
+func SortHotels(hotels []Hotel, order string) []Hotel {
+	out := make([]Hotel, len(hotels))
+	copy(out, hotels)
+
+	var less func(a, b Hotel) (bool, bool)
+	switch order {
+	case models.SortPriceDesc:
+		less = func(a, b Hotel) (bool, bool) { return a.Price > b.Price, a.Price != b.Price }
+	case models.SortName:
+		less = func(a, b Hotel) (bool, bool) {
+			an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+			return an < bn, an != bn
+		}
+	case models.SortRating:
+		less = func(a, b Hotel) (bool, bool) { return a.Rating > b.Rating, a.Rating != b.Rating }
+	case models.SortRelevance:
+		scores := relevanceScores(out)
+		less = func(a, b Hotel) (bool, bool) {
+			sa, sb := scores[a.HotelID], scores[b.HotelID]
+			return sa > sb, sa != sb
+		}
+	default:
+		less = func(a, b Hotel) (bool, bool) { return a.Price < b.Price, a.Price != b.Price }
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if l, decided := less(out[i], out[j]); decided {
+			return l
+		}
+		return out[i].HotelID < out[j].HotelID
+	})
+	return out
+}
+
+// relevanceScores blends guest rating (50%), price relative to the cheapest
+// hotel (30%) and supplier coverage (20%) into a 0..1 score.
+func relevanceScores(hotels []Hotel) map[string]float64 {
+	minPrice, maxOffers := 0.0, 0
+	for _, h := range hotels {
+		if minPrice == 0 || (h.Price > 0 && h.Price < minPrice) {
+			minPrice = h.Price
+		}
+		if len(h.Offers) > maxOffers {
+			maxOffers = len(h.Offers)
+		}
+	}
+	scores := make(map[string]float64, len(hotels))
+	for _, h := range hotels {
+		score := 0.5 * h.Rating / 10
+		if h.Price > 0 {
+			score += 0.3 * minPrice / h.Price
+		}
+		if maxOffers > 0 {
+			score += 0.2 * float64(len(h.Offers)) / float64(maxOffers)
+		}
+		scores[h.HotelID] = score
+	}
+	return scores
+}
+
+// paginate cuts one page out of sorted hotels. generation identifies the
+// ordered list, see resultGeneration.
+func paginate(hotels []Hotel, page models.PageRequest, generation int64, query string) ([]Hotel, PageInfo, error) {
+	info := PageInfo{Limit: page.Limit, Total: len(hotels)}
+	if page.Limit == 0 {
+		return hotels, info, nil
+	}
+
+	offset := 0
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, info, err
+		}
+		if c.Generation != generation || c.Query != query || c.Offset > len(hotels) {
+			return nil, info, ErrInvalidCursor
+		}
+		offset = c.Offset
+	}
+
+	end := offset + page.Limit
+	if end > len(hotels) {
+		end = len(hotels)
+	}
+	if end < len(hotels) {
+		info.NextCursor = encodeCursor(pageCursor{Offset: end, Generation: generation, Query: query})
+	}
+	return hotels[offset:end], info, nil
+}
@@ -0,0 +1,125 @@
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+)
+
+func ids(hotels []Hotel) []string {
+	out := make([]string, len(hotels))
+	for i, h := range hotels {
+		out[i] = h.HotelID
+	}
+	return out
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSortHotels(t *testing.T) {
+	hotels := []Hotel{
+		{HotelID: "H3", Name: "kasbah", Price: 100, Rating: 8, Offers: make([]Offer, 1)},
+		{HotelID: "H1", Name: "Atlas", Price: 120, Rating: 9, Offers: make([]Offer, 3)},
+		{HotelID: "H2", Name: "riad", Price: 100, Rating: 9, Offers: make([]Offer, 2)},
+		{HotelID: "H4", Name: "Zenith", Price: 300, Rating: 6, Offers: make([]Offer, 1)},
+	}
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{models.SortPrice, []string{"H2", "H3", "H1", "H4"}},
+		{"", []string{"H2", "H3", "H1", "H4"}},
+		{models.SortPriceDesc, []string{"H4", "H1", "H2", "H3"}},
+		{models.SortName, []string{"H1", "H3", "H2", "H4"}},
+		{models.SortRating, []string{"H1", "H2", "H3", "H4"}},
+		{models.SortRelevance, []string{"H1", "H2", "H3", "H4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			got := ids(SortHotels(hotels, tt.order))
+			if !equalIDs(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if hotels[0].HotelID != "H3" {
+		t.Fatal("input slice was reordered")
+	}
+}
+
+func TestPaginate_WalksAllPages(t *testing.T) {
+	hotels := make([]Hotel, 5)
+	for i := range hotels {
+		hotels[i] = Hotel{HotelID: string(rune('A' + i))}
+	}
+	page := models.PageRequest{Limit: 2}
+	var seen []string
+	for i := 0; i < 5; i++ {
+		got, info, err := paginate(hotels, page, 42, "q")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Total != 5 {
+			t.Fatalf("expected total 5, got %d", info.Total)
+		}
+		seen = append(seen, ids(got)...)
+		if info.NextCursor == "" {
+			break
+		}
+		page.Cursor = info.NextCursor
+	}
+	if !equalIDs(seen, []string{"A", "B", "C", "D", "E"}) {
+		t.Fatalf("pages did not cover the result exactly once: %v", seen)
+	}
+}
+
+func TestResultGeneration(t *testing.T) {
+	a := []Hotel{{HotelID: "A", Price: 100}, {HotelID: "B", Price: 120}}
+	repriced := []Hotel{{HotelID: "A", Price: 90}, {HotelID: "B", Price: 125}}
+	if resultGeneration(a) != resultGeneration(repriced) {
+		t.Fatal("expected a recomputation listing the same hotels to keep its generation")
+	}
+	reordered := []Hotel{{HotelID: "B"}, {HotelID: "A"}}
+	grown := []Hotel{{HotelID: "A"}, {HotelID: "B"}, {HotelID: "C"}}
+	if resultGeneration(a) == resultGeneration(reordered) || resultGeneration(a) == resultGeneration(grown) {
+		t.Fatal("expected a different generation when offsets shift")
+	}
+}
+
+func TestPaginate_RejectsForeignCursors(t *testing.T) {
+	hotels := []Hotel{{HotelID: "A"}, {HotelID: "B"}, {HotelID: "C"}}
+	_, info, err := paginate(hotels, models.PageRequest{Limit: 1}, 42, "q")
+	if err != nil || info.NextCursor == "" {
+		t.Fatalf("expected a next cursor, got %+v %v", info, err)
+	}
+
+	tests := []struct {
+		name       string
+		cursor     string
+		generation int64
+		query      string
+	}{
+		{"ChangedResult", info.NextCursor, 43, "q"},
+		{"OtherQuery", info.NextCursor, 42, "other"},
+		{"Garbage", "not-a-cursor!", 42, "q"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := paginate(hotels, models.PageRequest{Limit: 1, Cursor: tt.cursor}, tt.generation, tt.query)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
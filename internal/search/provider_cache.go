@@ -0,0 +1,93 @@
+package search
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// providerCache keeps each provider's raw hotels per request so the
+// aggregator only re-queries providers whose slice is missing or expired.
+// Failures are never stored. When full, the least recently used slice makes
+// room for a new one.
+type providerCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	items      map[string]*list.Element
+	lru        *list.List // most recently used first
+	now        func() time.Time
+}
+
+type providerSlice struct {
+	key      string
+	hotels   []Hotel
+	storedAt time.Time
+	expiry   time.Time
+}
+
+func newProviderCache(ttl time.Duration, maxEntries int) *providerCache {
+	return &providerCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func providerCacheKey(provider, key string) string {
+	return provider + "|" + key
+}
+
+// get returns a live slice and when the provider answered it.
+func (c *providerCache) get(provider, key string) ([]Hotel, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[providerCacheKey(provider, key)]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	s := el.Value.(*providerSlice)
+	if !c.now().Before(s.expiry) {
+		c.remove(el)
+		return nil, time.Time{}, false
+	}
+	c.lru.MoveToFront(el)
+	return s.hotels, s.storedAt, true
+}
+
+// put stores a successful provider answer, evicting the least recently used
+// slice when the cache is full.
+func (c *providerCache) put(provider, key string, hotels []Hotel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	s := &providerSlice{key: providerCacheKey(provider, key), hotels: hotels, storedAt: now, expiry: now.Add(c.ttl)}
+	if el, ok := c.items[s.key]; ok {
+		el.Value = s
+		c.lru.MoveToFront(el)
+		return
+	}
+	if c.maxEntries > 0 && c.lru.Len() >= c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+	c.items[s.key] = c.lru.PushFront(s)
+}
+
+// sweep removes expired slices.
+func (c *providerCache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for _, el := range c.items {
+		if !now.Before(el.Value.(*providerSlice).expiry) {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *providerCache) remove(el *list.Element) {
+	delete(c.items, el.Value.(*providerSlice).key)
+	c.lru.Remove(el)
+}
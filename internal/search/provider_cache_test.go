@@ -0,0 +1,44 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProviderCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(1700000000, 0)}
+	c := newProviderCache(time.Minute, 2)
+	c.now = clk.Now
+	hotels := []Hotel{{HotelID: "h1"}}
+
+	c.put("p", "a", hotels)
+	c.put("p", "b", hotels)
+	c.get("p", "a")
+	c.put("p", "c", hotels)
+	if _, _, ok := c.get("p", "b"); ok {
+		t.Fatal("expected the least recently used slice to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, _, ok := c.get("p", k); !ok {
+			t.Fatalf("expected slice %s to be kept", k)
+		}
+	}
+}
+
+func TestProviderCache_Sweep(t *testing.T) {
+	clk := &fakeClock{t: time.Unix(1700000000, 0)}
+	c := newProviderCache(time.Minute, 0)
+	c.now = clk.Now
+
+	c.put("p", "old", nil)
+	clk.Advance(30 * time.Second)
+	c.put("p", "new", nil)
+	clk.Advance(40 * time.Second)
+	c.sweep()
+	if len(c.items) != 1 || c.lru.Len() != 1 {
+		t.Fatalf("expected only the live slice to remain, got %d", len(c.items))
+	}
+	if _, _, ok := c.get("p", "new"); !ok {
+		t.Fatal("expected the live slice to survive the sweep")
+	}
+}
@@ -1,48 +1,333 @@
 package search
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 )
 
+// RateLimiter decides whether the client identified by id may make another
+// request under plan. Limiters key their state on id alone and read the
+// limits from plan on every call, so a plan change applies immediately.
 type RateLimiter interface {
-	Allow(ip string) bool
+	Allow(id string, plan Plan) Decision
 }
 
-// Simple token bucket per IP
-type ipBucket struct {
-	tokens     int
+// Decision is the outcome of a rate limit check and the state of the limit
+// that is closest to being exhausted. A zero Limit means the client is not
+// limited.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long a denied client should wait before retrying.
+	RetryAfter time.Duration
+}
+
+var unlimited = Decision{Allowed: true}
+
+// Plan is the allowance of a client. A zero field disables that limit.
+type Plan struct {
+	Name              string `json:"-"`
+	RequestsPerMinute int    `json:"rpm"`
+	// Burst is how many requests may be made back to back, RequestsPerMinute
+	// when unset.
+	Burst int `json:"burst"`
+	// DailyQuota caps allowed requests per UTC day.
+	DailyQuota int `json:"daily_quota"`
+}
+
+// DefaultPlan applies to clients without an API key, keyed by IP.
+var DefaultPlan = Plan{Name: "anonymous", RequestsPerMinute: 10, Burst: 10}
+
+func (p Plan) burst() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.RequestsPerMinute
+}
+
+// Rate limiting algorithms accepted by NewRateLimiter.
+const (
+	RateLimitTokenBucket   = "token-bucket"
+	RateLimitSlidingWindow = "sliding-window"
+	RateLimitGCRA          = "gcra"
+)
+
+// LocalRateLimiter is a RateLimiter keeping its clients in process. Run
+// forgets idle ones until its context is cancelled.
+type LocalRateLimiter interface {
+	RateLimiter
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// NewRateLimiter returns a limiter using the named algorithm for the per
+// minute limit, an empty name selects the token bucket. Daily quotas are
+// enforced on top of any algorithm. opts apply to both.
+func NewRateLimiter(algorithm string, opts ...LimiterOption) (LocalRateLimiter, error) {
+	var rl RateLimiter
+	switch algorithm {
+	case "", RateLimitTokenBucket:
+		rl = NewTokenBucketLimiter(opts...)
+	case RateLimitSlidingWindow:
+		rl = NewSlidingWindowLimiter(opts...)
+	case RateLimitGCRA:
+		rl = NewGCRALimiter(opts...)
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", algorithm)
+	}
+	return NewDailyQuotaLimiter(rl, opts...), nil
+}
+
+// sweeper is implemented by limiters that can forget idle clients.
+type sweeper interface {
+	sweep()
+}
+
+// Token bucket per client holding up to the plan's burst, refilled
+// continuously at its requests per minute so a client cannot burst twice
+// across a refill boundary.
+type tokenBucket struct {
+	tokens     float64
 	lastRefill time.Time
 }
 
-type ipRateLimiter struct {
-	mu             sync.Mutex
-	buckets        map[string]*ipBucket
-	cap            int
-	refillDuration time.Duration
+type tokenBucketLimiter struct {
+	mu      sync.Mutex
+	buckets *limiterKeys[tokenBucket]
+	now     func() time.Time
+}
+
+func NewTokenBucketLimiter(opts ...LimiterOption) *tokenBucketLimiter {
+	cfg := newLimiterConfig(opts)
+	return &tokenBucketLimiter{buckets: newLimiterKeys[tokenBucket](RateLimitTokenBucket, cfg), now: time.Now}
+}
+
+func (rl *tokenBucketLimiter) Allow(id string, plan Plan) Decision {
+	if plan.RequestsPerMinute <= 0 {
+		return unlimited
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	capacity := float64(plan.burst())
+	now := rl.now()
+	e := rl.buckets.get(id, now)
+	if e == nil {
+		return rl.buckets.overflowDecision()
+	}
+	b := &e.state
+	if b.lastRefill.IsZero() {
+		*b = tokenBucket{tokens: capacity, lastRefill: now}
+	}
+	elapsed := now.Sub(b.lastRefill)
+	b.tokens = min(capacity, b.tokens+float64(plan.RequestsPerMinute)*elapsed.Minutes())
+	b.lastRefill = now
+	// time to earn n tokens at the plan's rate
+	earn := func(n float64) time.Duration {
+		return time.Duration(n / float64(plan.RequestsPerMinute) * float64(time.Minute))
+	}
+	d := Decision{Limit: plan.burst()}
+	if b.tokens < 1 {
+		d.RetryAfter = earn(1 - b.tokens)
+	} else {
+		b.tokens--
+		d.Allowed = true
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = earn(capacity - b.tokens)
+	e.fullAt = now.Add(d.Reset)
+	return d
+}
+
+func (rl *tokenBucketLimiter) sweep() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.buckets.sweep(rl.now())
+}
+
+// slidingWindowLimiter keeps the time of every allowed request in the last
+// minute per client and allows at most the plan's requests per minute. Exact,
+// but memory grows with the limit, and Burst does not apply.
+type slidingWindowLimiter struct {
+	mu   sync.Mutex
+	logs *limiterKeys[[]time.Time]
+	now  func() time.Time
+}
+
+func NewSlidingWindowLimiter(opts ...LimiterOption) *slidingWindowLimiter {
+	cfg := newLimiterConfig(opts)
+	return &slidingWindowLimiter{logs: newLimiterKeys[[]time.Time](RateLimitSlidingWindow, cfg), now: time.Now}
+}
+
+func (rl *slidingWindowLimiter) Allow(id string, plan Plan) Decision {
+	if plan.RequestsPerMinute <= 0 {
+		return unlimited
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	e := rl.logs.get(id, now)
+	if e == nil {
+		return rl.logs.overflowDecision()
+	}
+	times := e.state
+	// drop requests that left the window; the log is in arrival order
+	i := 0
+	for i < len(times) && !times[i].After(now.Add(-time.Minute)) {
+		i++
+	}
+	times = times[i:]
+	d := Decision{Limit: plan.RequestsPerMinute}
+	if len(times) >= plan.RequestsPerMinute {
+		// a slot frees up when the oldest request leaves the window
+		d.RetryAfter = times[0].Add(time.Minute).Sub(now)
+	} else {
+		times = append(times, now)
+		d.Allowed = true
+	}
+	e.state = times
+	d.Remaining = plan.RequestsPerMinute - len(times)
+	d.Reset = times[len(times)-1].Add(time.Minute).Sub(now)
+	e.fullAt = now.Add(d.Reset)
+	return d
+}
+
+func (rl *slidingWindowLimiter) sweep() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.logs.sweep(rl.now())
 }
 
-func NewIPRateLimiter(cap int, refill time.Duration) *ipRateLimiter {
-	return &ipRateLimiter{buckets: make(map[string]*ipBucket), cap: cap, refillDuration: refill}
+// gcraLimiter implements the generic cell rate algorithm: each client only
+// stores the theoretical arrival time of its next request. Requests are
+// spaced a minute/rpm apart with a burst of the plan's burst.
+type gcraLimiter struct {
+	mu  sync.Mutex
+	tat *limiterKeys[time.Time]
+	now func() time.Time
 }
 
-func (rl *ipRateLimiter) Allow(ip string) bool {
+func NewGCRALimiter(opts ...LimiterOption) *gcraLimiter {
+	cfg := newLimiterConfig(opts)
+	return &gcraLimiter{tat: newLimiterKeys[time.Time](RateLimitGCRA, cfg), now: time.Now}
+}
+
+func (rl *gcraLimiter) Allow(id string, plan Plan) Decision {
+	if plan.RequestsPerMinute <= 0 {
+		return unlimited
+	}
+	interval := time.Minute / time.Duration(plan.RequestsPerMinute)
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
-	b, ok := rl.buckets[ip]
-	now := time.Now()
-	if !ok {
-		rl.buckets[ip] = &ipBucket{tokens: rl.cap - 1, lastRefill: now}
-		return true
-	}
-	// refill if interval passed
-	if now.Sub(b.lastRefill) >= rl.refillDuration {
-		b.tokens = rl.cap
-		b.lastRefill = now
-	}
-	if b.tokens <= 0 {
-		return false
-	}
-	b.tokens--
-	return true
+	now := rl.now()
+	e := rl.tat.get(id, now)
+	if e == nil {
+		return rl.tat.overflowDecision()
+	}
+	tat := e.state
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	// the tat may run at most burst intervals ahead of now
+	window := interval * time.Duration(plan.burst())
+	d := Decision{Limit: plan.burst()}
+	if ahead := next.Sub(now); ahead > window {
+		d.RetryAfter = ahead - window
+	} else {
+		e.state = next
+		tat = next
+		d.Allowed = true
+	}
+	d.Remaining = int((window - tat.Sub(now)) / interval)
+	d.Reset = tat.Sub(now)
+	e.fullAt = tat
+	return d
+}
+
+func (rl *gcraLimiter) sweep() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.tat.sweep(rl.now())
+}
+
+// dailyQuotaLimiter enforces the plan's daily quota in front of another
+// limiter. Only requests that limiter allows count against the quota.
+// Counters are never evicted to make room for other clients, which would
+// hand the evicted client a fresh quota; only plans from the key store have
+// one, so they stay bounded by it.
+type dailyQuotaLimiter struct {
+	mu   sync.Mutex
+	next RateLimiter
+	used *limiterKeys[dailyUsage]
+	now  func() time.Time
+}
+
+type dailyUsage struct {
+	day   string
+	count int
+}
+
+func NewDailyQuotaLimiter(next RateLimiter, opts ...LimiterOption) *dailyQuotaLimiter {
+	cfg := newLimiterConfig(opts)
+	cfg.maxKeys = 0
+	return &dailyQuotaLimiter{next: next, used: newLimiterKeys[dailyUsage]("daily-quota", cfg), now: time.Now}
+}
+
+func (rl *dailyQuotaLimiter) Allow(id string, plan Plan) Decision {
+	if plan.DailyQuota <= 0 {
+		return rl.next.Allow(id, plan)
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now().UTC()
+	e := rl.used.get(id, now)
+	if e == nil {
+		return rl.used.overflowDecision()
+	}
+	day := now.Format("2006-01-02")
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	e.fullAt = midnight
+	u := &e.state
+	if u.day != day {
+		*u = dailyUsage{day: day}
+	}
+	if u.count >= plan.DailyQuota {
+		return Decision{Limit: plan.DailyQuota, Reset: midnight.Sub(now), RetryAfter: midnight.Sub(now)}
+	}
+	d := rl.next.Allow(id, plan)
+	if d.Allowed {
+		u.count++
+	}
+	// report the quota once it is the tighter limit
+	if left := plan.DailyQuota - u.count; d.Limit == 0 || left < d.Remaining {
+		d.Limit, d.Remaining, d.Reset = plan.DailyQuota, left, midnight.Sub(now)
+	}
+	return d
+}
+
+func (rl *dailyQuotaLimiter) sweep() {
+	rl.mu.Lock()
+	rl.used.sweep(rl.now())
+	rl.mu.Unlock()
+	if s, ok := rl.next.(sweeper); ok {
+		s.sweep()
+	}
+}
+
+// Run forgets idle clients every interval until ctx is cancelled.
+func (rl *dailyQuotaLimiter) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			rl.sweep()
+		}
+	}
 }
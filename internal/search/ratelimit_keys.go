@@ -0,0 +1,147 @@
+package search
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+)
+
+const (
+	DefaultRateLimitMaxKeys     = 100000
+	DefaultRateLimitIdleTimeout = 10 * time.Minute
+)
+
+// Overflow policies applied when a new client arrives while a limiter already
+// tracks its maximum number of keys.
+const (
+	// OverflowEvictOldest forgets the least recently seen client, which gets
+	// a fresh allowance if it comes back.
+	OverflowEvictOldest = "evict-oldest"
+	// OverflowReject denies new clients until keys expire.
+	OverflowReject = "reject"
+	// OverflowAllow lets new clients through without limiting them.
+	OverflowAllow = "allow"
+)
+
+// LimiterOption configures how a rate limiter tracks clients.
+type LimiterOption func(*limiterConfig)
+
+type limiterConfig struct {
+	maxKeys     int
+	overflow    string
+	idleTimeout time.Duration
+	metrics     *obs.Metrics
+}
+
+func newLimiterConfig(opts []LimiterOption) limiterConfig {
+	cfg := limiterConfig{
+		maxKeys:     DefaultRateLimitMaxKeys,
+		overflow:    OverflowEvictOldest,
+		idleTimeout: DefaultRateLimitIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithMaxKeys caps the clients tracked by a limiter and sets what happens to
+// new clients once the cap is reached; 0 disables the cap.
+func WithMaxKeys(n int, overflow string) LimiterOption {
+	return func(c *limiterConfig) {
+		c.maxKeys = n
+		c.overflow = overflow
+	}
+}
+
+// WithIdleTimeout sets how long a client whose allowance is full again must
+// stay idle before the janitor forgets it.
+func WithIdleTimeout(d time.Duration) LimiterOption {
+	return func(c *limiterConfig) { c.idleTimeout = d }
+}
+
+// WithLimiterMetrics reports the number of tracked clients.
+func WithLimiterMetrics(m *obs.Metrics) LimiterOption {
+	return func(c *limiterConfig) { c.metrics = m }
+}
+
+// limiterKeys holds per-client limiter state, most recently seen first. It
+// is not safe for concurrent use; limiters guard it with their own mutex.
+type limiterKeys[T any] struct {
+	name  string // limiter label in the tracked keys gauge
+	cfg   limiterConfig
+	items map[string]*list.Element
+	lru   *list.List
+}
+
+type limiterKey[T any] struct {
+	id       string
+	state    T
+	lastSeen time.Time
+	// fullAt is when state is back to a new client's, so forgetting the key
+	// after it changes nothing.
+	fullAt time.Time
+}
+
+func newLimiterKeys[T any](name string, cfg limiterConfig) *limiterKeys[T] {
+	return &limiterKeys[T]{name: name, cfg: cfg, items: make(map[string]*list.Element), lru: list.New()}
+}
+
+// get returns the state of id, tracking it if new. It returns nil when id is
+// new, the cap is reached and the overflow policy does not evict; the caller
+// then answers with overflowDecision.
+func (k *limiterKeys[T]) get(id string, now time.Time) *limiterKey[T] {
+	if el, ok := k.items[id]; ok {
+		k.lru.MoveToFront(el)
+		e := el.Value.(*limiterKey[T])
+		e.lastSeen = now
+		return e
+	}
+	if k.cfg.maxKeys > 0 && len(k.items) >= k.cfg.maxKeys {
+		if k.cfg.overflow != OverflowEvictOldest {
+			return nil
+		}
+		k.remove(k.lru.Back())
+	}
+	e := &limiterKey[T]{id: id, lastSeen: now}
+	k.items[id] = k.lru.PushFront(e)
+	k.report()
+	return e
+}
+
+func (k *limiterKeys[T]) overflowDecision() Decision {
+	if k.cfg.overflow == OverflowAllow {
+		return unlimited
+	}
+	return Decision{RetryAfter: time.Second}
+}
+
+// sweep forgets clients whose allowance is full again and that have been
+// idle for the idle timeout.
+func (k *limiterKeys[T]) sweep(now time.Time) {
+	for el := k.lru.Back(); el != nil; {
+		e := el.Value.(*limiterKey[T])
+		prev := el.Prev()
+		// the list is ordered by lastSeen, nothing further is idle enough
+		if now.Sub(e.lastSeen) < k.cfg.idleTimeout {
+			break
+		}
+		if !now.Before(e.fullAt) {
+			k.remove(el)
+		}
+		el = prev
+	}
+	k.report()
+}
+
+func (k *limiterKeys[T]) remove(el *list.Element) {
+	delete(k.items, el.Value.(*limiterKey[T]).id)
+	k.lru.Remove(el)
+}
+
+func (k *limiterKeys[T]) report() {
+	if k.cfg.metrics != nil {
+		k.cfg.metrics.SetRateLimitKeys(k.name, len(k.items))
+	}
+}
@@ -1,13 +1,265 @@
 package search
 
 import (
+	"strconv"
 	"testing"
 	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestRateLimiter(t *testing.T) {
-	rl := NewIPRateLimiter(2, time.Minute)
-	if !rl.Allow("1.1.1.1") { t.Fatal("expected allow") }
-	if !rl.Allow("1.1.1.1") { t.Fatal("expected allow") }
-	if rl.Allow("1.1.1.1") { t.Fatal("expected deny") }
+	rl := NewTokenBucketLimiter()
+	plan := Plan{RequestsPerMinute: 2}
+	if !rl.Allow("1.1.1.1", plan).Allowed { t.Fatal("expected allow") }
+	if !rl.Allow("1.1.1.1", plan).Allowed { t.Fatal("expected allow") }
+	if rl.Allow("1.1.1.1", plan).Allowed { t.Fatal("expected deny") }
+}
+
+// newTestLimiter builds the named limiter, quota included, on clk.
+func newTestLimiter(t *testing.T, algorithm string, clk *fakeClock) RateLimiter {
+	t.Helper()
+	l, err := NewRateLimiter(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := l.(*dailyQuotaLimiter)
+	rl.now = clk.Now
+	switch l := rl.next.(type) {
+	case *tokenBucketLimiter:
+		l.now = clk.Now
+	case *slidingWindowLimiter:
+		l.now = clk.Now
+	case *gcraLimiter:
+		l.now = clk.Now
+	}
+	return rl
+}
+
+var twoPerMinute = Plan{RequestsPerMinute: 2, Burst: 2}
+
+func TestRateLimiterAlgorithms(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		want    []bool
+	}
+	tests := []struct {
+		name      string
+		algorithm string
+		steps     []step
+	}{
+		{"token bucket burst", RateLimitTokenBucket, []step{{0, []bool{true, true, false}}}},
+		{"token bucket refills continuously", RateLimitTokenBucket, []step{
+			{0, []bool{true, true, false}},
+			{30 * time.Second, []bool{true, false}},
+		}},
+		{"token bucket no double burst across refill", RateLimitTokenBucket, []step{
+			{0, []bool{true}},
+			{59 * time.Second, []bool{true, true, false}},
+			{2 * time.Second, []bool{false}},
+		}},
+		{"sliding window burst", RateLimitSlidingWindow, []step{{0, []bool{true, true, false}}}},
+		{"sliding window waits for the oldest request", RateLimitSlidingWindow, []step{
+			{0, []bool{true}},
+			{30 * time.Second, []bool{true, false}},
+			{30 * time.Second, []bool{true, false}},
+			{30 * time.Second, []bool{true, false}},
+		}},
+		{"gcra burst", RateLimitGCRA, []step{{0, []bool{true, true, false}}}},
+		{"gcra spaces requests", RateLimitGCRA, []step{
+			{0, []bool{true, true, false}},
+			{29 * time.Second, []bool{false}},
+			{time.Second, []bool{true, false}},
+		}},
+		{"gcra no double burst across refill", RateLimitGCRA, []step{
+			{0, []bool{true}},
+			{59 * time.Second, []bool{true, true, false}},
+			{2 * time.Second, []bool{false}},
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clk := &fakeClock{t: time.Unix(1700000000, 0)}
+			rl := newTestLimiter(t, tc.algorithm, clk)
+			for i, s := range tc.steps {
+				clk.Advance(s.advance)
+				for j, want := range s.want {
+					if got := rl.Allow("1.1.1.1", twoPerMinute).Allowed; got != want {
+						t.Fatalf("step %d request %d: expected allow=%v", i, j, want)
+					}
+				}
+			}
+			// keys are limited independently
+			if !rl.Allow("2.2.2.2", twoPerMinute).Allowed {
+				t.Fatal("expected another key to be allowed")
+			}
+		})
+	}
+}
+
+func TestNewRateLimiter_UnknownAlgorithm(t *testing.T) {
+	if _, err := NewRateLimiter("leaky"); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+}
+
+func TestRateLimiterPlans(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2025, 1, 1, 23, 59, 0, 0, time.UTC)}
+	rl := newTestLimiter(t, RateLimitTokenBucket, clk)
+
+	// burst is separate from the sustained rate
+	bursty := Plan{RequestsPerMinute: 60, Burst: 3}
+	for i := 0; i < 3; i++ {
+		if !rl.Allow("a", bursty).Allowed {
+			t.Fatalf("request %d: expected allow within burst", i)
+		}
+	}
+	if rl.Allow("a", bursty).Allowed {
+		t.Fatal("expected deny once the burst is spent")
+	}
+	clk.Advance(time.Second)
+	if !rl.Allow("a", bursty).Allowed {
+		t.Fatal("expected a token after one second at 60 rpm")
+	}
+
+	// the daily quota holds regardless of the per minute limit and resets at
+	// midnight UTC
+	quota := Plan{RequestsPerMinute: 100, DailyQuota: 2}
+	if !rl.Allow("b", quota).Allowed || !rl.Allow("b", quota).Allowed || rl.Allow("b", quota).Allowed {
+		t.Fatal("expected exactly 2 requests within the daily quota")
+	}
+	clk.Advance(time.Minute)
+	if !rl.Allow("b", quota).Allowed {
+		t.Fatal("expected the quota to reset on a new day")
+	}
+
+	if !rl.Allow("c", Plan{}).Allowed {
+		t.Fatal("expected a plan without limits to allow")
+	}
+}
+
+func TestRateLimiterDecisions(t *testing.T) {
+	tests := []struct {
+		algorithm  string
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		// after two requests at once the bucket and gcra free a slot every
+		// 30s, the sliding window only when the first request leaves it
+		{RateLimitTokenBucket, 30 * time.Second, time.Minute},
+		{RateLimitSlidingWindow, time.Minute, time.Minute},
+		{RateLimitGCRA, 30 * time.Second, time.Minute},
+	}
+	for _, tc := range tests {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			clk := &fakeClock{t: time.Unix(1700000000, 0)}
+			rl := newTestLimiter(t, tc.algorithm, clk)
+			if d := rl.Allow("a", twoPerMinute); !d.Allowed || d.Limit != 2 || d.Remaining != 1 {
+				t.Fatalf("unexpected first decision %+v", d)
+			}
+			rl.Allow("a", twoPerMinute)
+			d := rl.Allow("a", twoPerMinute)
+			if d.Allowed || d.Remaining != 0 || d.RetryAfter != tc.retryAfter || d.Reset != tc.reset {
+				t.Fatalf("unexpected denial %+v", d)
+			}
+		})
+	}
+}
+
+func TestRateLimiterDecisions_DailyQuota(t *testing.T) {
+	clk := &fakeClock{t: time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)}
+	rl := newTestLimiter(t, RateLimitTokenBucket, clk)
+	plan := Plan{RequestsPerMinute: 100, DailyQuota: 2}
+
+	if d := rl.Allow("a", plan); d.Limit != 2 || d.Remaining != 1 || d.Reset != time.Hour {
+		t.Fatalf("expected the quota to be reported as the tighter limit, got %+v", d)
+	}
+	rl.Allow("a", plan)
+	if d := rl.Allow("a", plan); d.Allowed || d.RetryAfter != time.Hour {
+		t.Fatalf("expected a denial until midnight, got %+v", d)
+	}
+	if d := rl.Allow("b", Plan{}); !d.Allowed || d.Limit != 0 {
+		t.Fatalf("expected an unlimited decision, got %+v", d)
+	}
+}
+
+func TestRateLimiter_QuotaSurvivesMaxKeys(t *testing.T) {
+	rl, _ := NewRateLimiter(RateLimitGCRA, WithMaxKeys(1, OverflowEvictOldest))
+	quota := Plan{DailyQuota: 1}
+	rl.Allow("partner", quota)
+	// a scan of new clients evicts per minute state, never quota counters
+	for i := 0; i < 3; i++ {
+		rl.Allow("scanner"+strconv.Itoa(i), Plan{DailyQuota: 5})
+	}
+	if d := rl.Allow("partner", quota); d.Allowed {
+		t.Fatalf("expected the spent quota to be kept, got %+v", d)
+	}
+}
+
+func TestRateLimiter_SweepsIdleKeys(t *testing.T) {
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	clk := &fakeClock{t: time.Unix(1700000000, 0)}
+	l, _ := NewRateLimiter(RateLimitTokenBucket, WithIdleTimeout(5*time.Minute), WithLimiterMetrics(m))
+	rl := l.(*dailyQuotaLimiter)
+	rl.now = clk.Now
+	rl.next.(*tokenBucketLimiter).now = clk.Now
+	slow := Plan{RequestsPerMinute: 1, Burst: 10} // empty, it refills in 10 minutes
+
+	rl.Allow("idle", twoPerMinute)
+	for i := 0; i < 10; i++ {
+		rl.Allow("refilling", slow)
+	}
+	clk.Advance(4 * time.Minute)
+	rl.Allow("active", twoPerMinute)
+	gauge := m.RateLimitKeys.WithLabelValues(RateLimitTokenBucket)
+	if got := testutil.ToFloat64(gauge); got != 3 {
+		t.Fatalf("expected 3 tracked keys, got %v", got)
+	}
+
+	clk.Advance(2 * time.Minute)
+	rl.sweep()
+	// idle is full and idle for 6m; refilling is idle but not yet full;
+	// active was seen 2m ago
+	if got := testutil.ToFloat64(gauge); got != 2 {
+		t.Fatalf("expected 2 tracked keys after the sweep, got %v", got)
+	}
+	// a sweep must not hand out a fresh allowance early
+	for i := 0; i < 4; i++ {
+		rl.Allow("refilling", slow)
+	}
+	if d := rl.Allow("refilling", slow); d.Remaining != 1 {
+		t.Fatalf("expected the refilling bucket to be kept, got %+v", d)
+	}
+}
+
+func TestRateLimiter_MaxKeys(t *testing.T) {
+	tests := []struct {
+		overflow string
+		want     bool // whether the new client is allowed
+		kept     bool // whether the oldest client is still limited
+	}{
+		{OverflowEvictOldest, true, false},
+		{OverflowReject, false, true},
+		{OverflowAllow, true, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.overflow, func(t *testing.T) {
+			clk := &fakeClock{t: time.Unix(1700000000, 0)}
+			rl := NewGCRALimiter(WithMaxKeys(2, tc.overflow))
+			rl.now = clk.Now
+			rl.Allow("a", twoPerMinute)
+			rl.Allow("a", twoPerMinute)
+			clk.Advance(time.Millisecond)
+			rl.Allow("b", twoPerMinute)
+
+			if got := rl.Allow("c", twoPerMinute).Allowed; got != tc.want {
+				t.Fatalf("expected allow=%v for a new client", tc.want)
+			}
+			if got := rl.Allow("a", twoPerMinute).Allowed; got == tc.kept {
+				t.Fatalf("expected the oldest client kept=%v", tc.kept)
+			}
+		})
+	}
 }
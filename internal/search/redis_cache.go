@@ -0,0 +1,411 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/resp"
+	"github.com/google/uuid"
+)
+
+// cacheEnvelopeVersion is bumped whenever the stored layout changes so
+// replicas running different versions ignore each other's entries.
+const cacheEnvelopeVersion = 1
+
+// cacheEnvelope is the serialized form of a shared cache entry.
+type cacheEnvelope struct {
+	Version  int              `json:"v"`
+	StoredAt time.Time        `json:"stored_at"`
+	Expiry   time.Time        `json:"expires_at"`
+	Result   AggregatedResult `json:"result"`
+	// Warmed is set when the cache warmer stored the entry.
+	Warmed bool `json:"warmed,omitempty"`
+}
+
+// redisCache shares results between replicas through a Redis compatible
+// server. Replicas computing the same key are coalesced through a lock key,
+// and whenever the backend is unreachable the local cache takes over.
+type redisCache struct {
+	client  *resp.Client
+	local   CacheService
+	prefix  string
+	ttl     time.Duration
+	lockTTL time.Duration
+	poll    time.Duration
+	metrics *obs.Metrics
+	now     func() time.Time
+
+	// degradedTTL and negativeTTL shorten results as in WithDegradedTTL and
+	// WithNegativeTTL.
+	degradedTTL time.Duration
+	negativeTTL time.Duration
+	// staleWhileRevalidate and staleIfError serve expired entries as in
+	// WithStaleWhileRevalidate and WithStaleIfError.
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+
+	// After a backend failure requests go straight to the local cache until
+	// downUntil, so an outage does not cost every request a dial timeout.
+	retryAfter time.Duration
+	mu         sync.Mutex
+	downUntil  time.Time
+}
+
+// RedisCacheOption configures optional redisCache behaviour.
+type RedisCacheOption func(*redisCache)
+
+// WithKeyPrefix namespaces every key written to the backend.
+func WithKeyPrefix(p string) RedisCacheOption {
+	return func(c *redisCache) { c.prefix = p }
+}
+
+// WithLockTTL bounds how long other replicas wait on a computation whose
+// owner died before releasing its lock.
+func WithLockTTL(d time.Duration) RedisCacheOption {
+	return func(c *redisCache) { c.lockTTL = d }
+}
+
+// WithBackendDegradedTTL stores results where a provider failed or was
+// skipped for at most d.
+func WithBackendDegradedTTL(d time.Duration) RedisCacheOption {
+	return func(c *redisCache) { c.degradedTTL = d }
+}
+
+// WithBackendNegativeTTL stores results without hotels for d. Errors are
+// never shared.
+func WithBackendNegativeTTL(d time.Duration) RedisCacheOption {
+	return func(c *redisCache) { c.negativeTTL = d }
+}
+
+// WithBackendStaleWhileRevalidate serves an entry for d past its expiry while
+// a single replica refreshes it in the background.
+func WithBackendStaleWhileRevalidate(d time.Duration) RedisCacheOption {
+	return func(c *redisCache) { c.staleWhileRevalidate = d }
+}
+
+// WithBackendStaleIfError serves an entry for d past its expiry when
+// recomputing it fails.
+func WithBackendStaleIfError(d time.Duration) RedisCacheOption {
+	return func(c *redisCache) { c.staleIfError = d }
+}
+
+// WithBackendRetry sets how long the backend is bypassed after a failure.
+func WithBackendRetry(d time.Duration) RedisCacheOption {
+	return func(c *redisCache) { c.retryAfter = d }
+}
+
+// WithLockPoll sets how often a waiting replica checks for the result.
+func WithLockPoll(d time.Duration) RedisCacheOption {
+	return func(c *redisCache) { c.poll = d }
+}
+
+func NewRedisCache(client *resp.Client, local CacheService, ttl time.Duration, m *obs.Metrics, opts ...RedisCacheOption) *redisCache {
+	c := &redisCache{
+		client:  client,
+		local:   local,
+		prefix:  "hotel:search:",
+		ttl:     ttl,
+		lockTTL: 5 * time.Second,
+		poll:    25 * time.Millisecond,
+		metrics: m,
+		now:     time.Now,
+
+		retryAfter: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *redisCache) GetOrCompute(ctx context.Context, key string, fn func(ctx context.Context) (AggregatedResult, error)) (AggregatedResult, error) {
+	if c.backendDown() {
+		return c.local.GetOrCompute(ctx, key, fn)
+	}
+	env, ok, err := c.load(ctx, key)
+	if err != nil {
+		return c.fallback(ctx, key, fn, err)
+	}
+	now := c.now()
+	fresh := ok && now.Before(env.Expiry)
+	// the warmer recomputes entries about to expire so users keep finding
+	// them fresh, and leaves the others alone
+	margin, warming := warmMargin(ctx)
+	if fresh && warming && env.Expiry.Sub(now) > margin {
+		return c.view(env, CacheHit), nil
+	}
+	if ok && !warming {
+		if fresh {
+			c.observe(env)
+			return c.view(env, CacheHit), nil
+		}
+		// Soft-expired: serve stale now, one replica revalidates in the
+		// background
+		if now.Before(env.Expiry.Add(c.staleWhileRevalidate)) {
+			c.revalidate(ctx, key, fn)
+			c.observe(env)
+			return c.view(env, CacheStale), nil
+		}
+	}
+
+	res, err := c.computeOrWait(ctx, key, fn)
+	// an expired entry is kept so it can still be served if computing fails
+	if err != nil && ok && c.now().Before(env.Expiry.Add(c.staleIfError)) {
+		return c.view(env, CacheStaleError), nil
+	}
+	return res, err
+}
+
+// computeOrWait computes key under its lock, or waits for the replica
+// holding the lock to publish a fresh result.
+func (c *redisCache) computeOrWait(ctx context.Context, key string, fn func(ctx context.Context) (AggregatedResult, error)) (AggregatedResult, error) {
+	token := uuid.NewString()
+	for {
+		acquired, err := c.client.SetNX(ctx, c.lockKey(key), token, c.lockTTL)
+		if err != nil {
+			return c.fallback(ctx, key, fn, err)
+		}
+		if acquired {
+			return c.compute(ctx, key, token, fn)
+		}
+
+		// Another replica is computing: wait for its result. If the lock goes
+		// away without a result (failure or expiry) the next SETNX wins it.
+		select {
+		case <-ctx.Done():
+			return AggregatedResult{}, ctx.Err()
+		case <-time.After(c.poll):
+		}
+		env, ok, err := c.load(ctx, key)
+		if err != nil {
+			return c.fallback(ctx, key, fn, err)
+		}
+		if ok && c.now().Before(env.Expiry) {
+			return c.view(env, CacheCoalesced), nil
+		}
+	}
+}
+
+// revalidate recomputes a stale key in the background unless another
+// replica already holds its lock. The refresh is detached from the request
+// that noticed it and bounded by the lock TTL, so it never outlives its lock.
+func (c *redisCache) revalidate(ctx context.Context, key string, fn func(ctx context.Context) (AggregatedResult, error)) {
+	token := uuid.NewString()
+	acquired, err := c.client.SetNX(ctx, c.lockKey(key), token, c.lockTTL)
+	if err != nil {
+		c.backendError("lock", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.lockTTL)
+		defer cancel()
+		// a failed refresh leaves the stale entry for stale-if-error
+		c.compute(ctx, key, token, fn)
+	}()
+}
+
+func (c *redisCache) observe(env cacheEnvelope) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.IncCacheHits()
+	if env.Warmed {
+		c.metrics.IncCacheWarmHits()
+	}
+}
+
+// compute runs fn while holding the lock for key and publishes a successful
+// result. Errors are not stored.
+func (c *redisCache) compute(ctx context.Context, key, token string, fn func(ctx context.Context) (AggregatedResult, error)) (AggregatedResult, error) {
+	defer c.unlock(key, token)
+
+	start := c.now()
+	res, err := fn(ctx)
+	if err != nil {
+		return AggregatedResult{}, err
+	}
+	start = dataTime(res, start)
+	env := cacheEnvelope{Version: cacheEnvelopeVersion, StoredAt: start, Expiry: start.Add(resultTTL(res, c.ttl, c.degradedTTL, c.negativeTTL)), Result: res, Warmed: isWarming(ctx)}
+	// the envelope outlives its expiry so it can be served stale
+	if ttl := env.Expiry.Add(max(c.staleWhileRevalidate, c.staleIfError)).Sub(c.now()); ttl > 0 {
+		b, err := json.Marshal(env)
+		if err == nil {
+			// the caller already has its result; a failed write only costs
+			// other replicas a recomputation
+			err = c.client.Set(context.WithoutCancel(ctx), c.dataKey(key), string(b), ttl)
+		}
+		if err != nil {
+			c.backendError("store", err)
+		}
+	}
+	return c.view(env, CacheMiss), nil
+}
+
+// unlock releases the lock if this caller still owns it. The check and the
+// delete are two commands, so a lock that expired in between and was taken
+// by another replica can be removed; that only costs a duplicate computation.
+func (c *redisCache) unlock(key, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	owner, err := c.client.Get(ctx, c.lockKey(key))
+	if err != nil || owner != token {
+		return
+	}
+	if _, err := c.client.Del(ctx, c.lockKey(key)); err != nil {
+		c.backendError("unlock", err)
+	}
+}
+
+// load fetches the entry for key. ok is false on a miss, including entries
+// written by an incompatible version.
+func (c *redisCache) load(ctx context.Context, key string) (cacheEnvelope, bool, error) {
+	_, env, ok, err := c.fetch(ctx, key)
+	return env, ok, err
+}
+
+func (c *redisCache) fallback(ctx context.Context, key string, fn func(ctx context.Context) (AggregatedResult, error), err error) (AggregatedResult, error) {
+	if ctx.Err() != nil {
+		return AggregatedResult{}, ctx.Err()
+	}
+	c.backendError("read", err)
+	c.mu.Lock()
+	c.downUntil = c.now().Add(c.retryAfter)
+	c.mu.Unlock()
+	return c.local.GetOrCompute(ctx, key, fn)
+}
+
+func (c *redisCache) backendDown() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now().Before(c.downUntil)
+}
+
+func (c *redisCache) backendError(op string, err error) {
+	log.Printf("cache backend %s failed: %v", op, err)
+	if c.metrics != nil {
+		c.metrics.IncCacheBackendErrors()
+	}
+}
+
+func (c *redisCache) view(env cacheEnvelope, status string) AggregatedResult {
+	now := c.now()
+	res := env.Result
+	res.Stats.Cache = status
+	res.Stats.CacheAgeMs = now.Sub(env.StoredAt).Milliseconds()
+	res.Stats.CacheTTLMs = env.Expiry.Sub(now).Milliseconds()
+	return res
+}
+
+// Admin operations act on the shared entries and on the local fallback
+// cache, so a purge also covers results computed during an outage.
+
+func (c *redisCache) List(ctx context.Context, prefix string) ([]CacheEntryInfo, error) {
+	keys, err := c.client.Scan(ctx, c.dataKey(resp.EscapeGlob(prefix))+"*")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]CacheEntryInfo, 0, len(keys))
+	for _, k := range keys {
+		key := strings.TrimPrefix(k, c.dataKey(""))
+		raw, env, ok, err := c.fetch(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, c.info(key, raw, env))
+		}
+	}
+	// the shared entry wins over a local one computed during an outage
+	shared := make(map[string]bool, len(out))
+	for _, e := range out {
+		shared[e.Key] = true
+	}
+	local, _ := c.local.List(ctx, prefix)
+	for _, e := range local {
+		if !shared[e.Key] {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func (c *redisCache) Get(ctx context.Context, key string) (CacheEntry, bool, error) {
+	raw, env, ok, err := c.fetch(ctx, key)
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+	if !ok {
+		return c.local.Get(ctx, key)
+	}
+	return CacheEntry{CacheEntryInfo: c.info(key, raw, env), Result: &env.Result}, true, nil
+}
+
+func (c *redisCache) Delete(ctx context.Context, key string) (bool, error) {
+	localHit, _ := c.local.Delete(ctx, key)
+	n, err := c.client.Del(ctx, c.dataKey(key))
+	return n > 0 || localHit, err
+}
+
+func (c *redisCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	localN, _ := c.local.DeletePrefix(ctx, prefix)
+	keys, err := c.client.Scan(ctx, c.dataKey(resp.EscapeGlob(prefix))+"*")
+	if err != nil {
+		return localN, err
+	}
+	var n int64
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), 500)]
+		keys = keys[len(batch):]
+		d, err := c.client.Del(ctx, batch...)
+		n += d
+		if err != nil {
+			return int(n), err
+		}
+	}
+	return max(int(n), localN), nil
+}
+
+func (c *redisCache) Flush(ctx context.Context) (int, error) {
+	return c.DeletePrefix(ctx, "")
+}
+
+// fetch is load that also returns the raw stored value.
+func (c *redisCache) fetch(ctx context.Context, key string) (string, cacheEnvelope, bool, error) {
+	var env cacheEnvelope
+	raw, err := c.client.Get(ctx, c.dataKey(key))
+	if errors.Is(err, resp.ErrNil) {
+		return "", env, false, nil
+	}
+	if err != nil {
+		return "", env, false, err
+	}
+	if err := json.Unmarshal([]byte(raw), &env); err != nil || env.Version != cacheEnvelopeVersion {
+		return "", env, false, nil
+	}
+	return raw, env, true, nil
+}
+
+func (c *redisCache) info(key, raw string, env cacheEnvelope) CacheEntryInfo {
+	now := c.now()
+	return CacheEntryInfo{
+		Key:   key,
+		AgeMs: now.Sub(env.StoredAt).Milliseconds(),
+		TTLMs: env.Expiry.Sub(now).Milliseconds(),
+		Bytes: int64(len(raw)),
+	}
+}
+
+func (c *redisCache) dataKey(key string) string { return fmt.Sprintf("%sdata:%s", c.prefix, key) }
+func (c *redisCache) lockKey(key string) string { return fmt.Sprintf("%slock:%s", c.prefix, key) }
@@ -0,0 +1,310 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/resp"
+	"github.com/example/mini-hotel-aggregator/internal/resp/resptest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestRedisCache(t *testing.T, srv *resptest.Server, m *obs.Metrics) *redisCache {
+	t.Helper()
+	client := resp.NewClient(srv.Addr(), resp.WithDialTimeout(100*time.Millisecond))
+	t.Cleanup(func() { client.Close() })
+	return NewRedisCache(client, NewCache(time.Minute, m), time.Minute, m, WithLockPoll(5*time.Millisecond))
+}
+
+func TestRedisCache_SharedBetweenReplicas(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	a, b := newTestRedisCache(t, srv, nil), newTestRedisCache(t, srv, nil)
+
+	computedAt := time.Now()
+	calls := 0
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		calls++
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1", Price: 99}}, ComputedAt: computedAt}, nil
+	}
+
+	res, err := a.GetOrCompute(context.Background(), "k", fn)
+	if err != nil || res.Stats.Cache != CacheMiss {
+		t.Fatalf("expected miss on first replica, got %q (%v)", res.Stats.Cache, err)
+	}
+	res, err = b.GetOrCompute(context.Background(), "k", fn)
+	if err != nil || res.Stats.Cache != CacheHit || len(res.Hotels) != 1 || res.Hotels[0].Price != 99 {
+		t.Fatalf("expected hit on second replica, got %+v (%v)", res, err)
+	}
+	// clients see computed_at, so it must survive serialization
+	if res.ComputedAt.UnixNano() != computedAt.UnixNano() {
+		t.Fatalf("computed_at changed in transit: %v != %v", res.ComputedAt, computedAt)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single computation, got %d", calls)
+	}
+	if _, ok := srv.Get("hotel:search:lock:k"); ok {
+		t.Fatal("expected lock to be released")
+	}
+}
+
+func TestRedisCache_CoalescesAcrossReplicas(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	a, b := newTestRedisCache(t, srv, nil), newTestRedisCache(t, srv, nil)
+
+	var calls int32
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+
+	var wg sync.WaitGroup
+	statuses := make(chan string, 6)
+	for i := 0; i < 6; i++ {
+		c := a
+		if i%2 == 1 {
+			c = b
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := c.GetOrCompute(context.Background(), "k", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			statuses <- res.Stats.Cache
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected one computation across replicas, got %d", n)
+	}
+	misses := 0
+	for s := range statuses {
+		if s == CacheMiss {
+			misses++
+		}
+	}
+	if misses != 1 {
+		t.Fatalf("expected exactly one miss, got %d", misses)
+	}
+}
+
+func TestRedisCache_ErrorsAreNotStored(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := newTestRedisCache(t, srv, nil)
+
+	boom := errors.New("fan-out failed")
+	if _, err := c.GetOrCompute(context.Background(), "k", func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{}, boom
+	}); !errors.Is(err, boom) {
+		t.Fatalf("expected computation error, got %v", err)
+	}
+	if keys := srv.Keys(); len(keys) != 0 {
+		t.Fatalf("expected nothing stored after an error, got %v", keys)
+	}
+}
+
+func TestRedisCache_FallsBackToLocal(t *testing.T) {
+	srv := resptest.NewServer()
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	c := newTestRedisCache(t, srv, m)
+	srv.Close()
+
+	calls := 0
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		calls++
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+	res, err := c.GetOrCompute(context.Background(), "k", fn)
+	if err != nil || res.Stats.Cache != CacheMiss {
+		t.Fatalf("expected local miss, got %q (%v)", res.Stats.Cache, err)
+	}
+	res, err = c.GetOrCompute(context.Background(), "k", fn)
+	if err != nil || res.Stats.Cache != CacheHit || calls != 1 {
+		t.Fatalf("expected local hit, got %q after %d computes (%v)", res.Stats.Cache, calls, err)
+	}
+	// the second call is served locally without touching the backend again
+	if got := testutil.ToFloat64(m.CacheBackendErrors); got != 1 {
+		t.Fatalf("expected 1 backend error, got %v", got)
+	}
+}
+
+func TestRedisCache_AdminOperations(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := newTestRedisCache(t, srv, nil)
+	ctx := context.Background()
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+	for _, k := range []string{"paris|a", "paris|b", "pa*is|c", "rome|a"} {
+		c.GetOrCompute(ctx, k, fn)
+	}
+
+	entries, err := c.List(ctx, "paris|")
+	if err != nil || len(entries) != 2 || entries[0].Key != "paris|a" || entries[0].TTLMs <= 0 {
+		t.Fatalf("unexpected listing %+v (%v)", entries, err)
+	}
+	if entry, ok, _ := c.Get(ctx, "rome|a"); !ok || entry.Result == nil {
+		t.Fatalf("expected rome entry, got %+v", entry)
+	}
+	if n, err := c.DeletePrefix(ctx, "pa*"); err != nil || n != 1 {
+		t.Fatalf("expected glob characters to match literally, deleted %d (%v)", n, err)
+	}
+	if n, err := c.Flush(ctx); err != nil || n != 3 {
+		t.Fatalf("expected 3 entries flushed, got %d (%v)", n, err)
+	}
+	if keys := srv.Keys(); len(keys) != 0 {
+		t.Fatalf("expected backend to be empty, got %v", keys)
+	}
+}
+
+func TestRedisCache_WarmsOnlyNearExpiry(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := newTestRedisCache(t, srv, nil)
+
+	calls := 0
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		calls++
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+	c.GetOrCompute(context.Background(), "k", fn)
+
+	// the entry has a minute left, more than the warmer's margin
+	c.GetOrCompute(withWarming(context.Background(), 10*time.Second), "k", fn)
+	if calls != 1 {
+		t.Fatalf("expected a fresh entry not to be rewarmed, got %d computations", calls)
+	}
+	c.GetOrCompute(withWarming(context.Background(), 2*time.Minute), "k", fn)
+	if calls != 2 {
+		t.Fatalf("expected an entry within the margin to be rewarmed, got %d computations", calls)
+	}
+}
+
+func TestRedisCache_ShortensDegradedAndEmptyResults(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	client := resp.NewClient(srv.Addr(), resp.WithDialTimeout(100*time.Millisecond))
+	defer client.Close()
+	c := NewRedisCache(client, NewCache(time.Minute, nil), time.Minute, nil,
+		WithBackendDegradedTTL(10*time.Second), WithBackendNegativeTTL(5*time.Second))
+
+	tests := []struct {
+		key  string
+		res  AggregatedResult
+		want time.Duration
+	}{
+		{"full", AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, time.Minute},
+		{"degraded", AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}, Stats: SearchStats{ProvidersFailed: 1}}, 10 * time.Second},
+		{"empty", AggregatedResult{}, 5 * time.Second},
+	}
+	for _, tc := range tests {
+		c.GetOrCompute(context.Background(), tc.key, func(ctx context.Context) (AggregatedResult, error) { return tc.res, nil })
+		env, ok, err := c.load(context.Background(), tc.key)
+		if err != nil || !ok {
+			t.Fatalf("%s: expected a stored entry, got %v", tc.key, err)
+		}
+		if got := env.Expiry.Sub(env.StoredAt); got != tc.want {
+			t.Fatalf("%s: expected a ttl of %v, got %v", tc.key, tc.want, got)
+		}
+	}
+}
+
+func TestRedisCache_StaleWhileRevalidate(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	client := resp.NewClient(srv.Addr(), resp.WithDialTimeout(100*time.Millisecond))
+	defer client.Close()
+	clk := &fakeClock{t: time.Unix(1700000000, 0)}
+	c := NewRedisCache(client, NewCache(time.Minute, nil), time.Minute, nil, WithBackendStaleWhileRevalidate(time.Minute))
+	c.now = clk.Now
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		n := calls.Add(1)
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1", Price: float64(n)}}}, nil
+	}
+	c.GetOrCompute(ctx, "k", fn)
+	clk.Advance(90 * time.Second)
+
+	res, err := c.GetOrCompute(ctx, "k", fn)
+	if err != nil || res.Stats.Cache != CacheStale || res.Hotels[0].Price != 1 {
+		t.Fatalf("expected the stale result at once, got %+v (%v)", res, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if env, ok, _ := c.load(ctx, "k"); ok && env.Result.Hotels[0].Price == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the stale entry to be refreshed in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	res, _ = c.GetOrCompute(ctx, "k", fn)
+	if res.Stats.Cache != CacheHit || res.Hotels[0].Price != 2 || calls.Load() != 2 {
+		t.Fatalf("expected a hit on the refreshed entry after 2 computations, got %+v after %d", res, calls.Load())
+	}
+}
+
+func TestRedisCache_StaleIfError(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	client := resp.NewClient(srv.Addr(), resp.WithDialTimeout(100*time.Millisecond))
+	defer client.Close()
+	clk := &fakeClock{t: time.Unix(1700000000, 0)}
+	c := NewRedisCache(client, NewCache(time.Minute, nil), time.Minute, nil, WithBackendStaleIfError(10*time.Minute))
+	c.now = clk.Now
+	ctx := context.Background()
+
+	c.GetOrCompute(ctx, "k", func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	})
+	failing := func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{}, ErrAllProvidersFailed
+	}
+	clk.Advance(5 * time.Minute)
+	res, err := c.GetOrCompute(ctx, "k", failing)
+	if err != nil || res.Stats.Cache != CacheStaleError || len(res.Hotels) != 1 {
+		t.Fatalf("expected the last good result, got %+v (%v)", res, err)
+	}
+	clk.Advance(10 * time.Minute)
+	if _, err := c.GetOrCompute(ctx, "k", failing); !errors.Is(err, ErrAllProvidersFailed) {
+		t.Fatalf("expected the error past the stale-if-error window, got %v", err)
+	}
+}
+
+func TestRedisCache_AdminIncludesLocal(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	c := newTestRedisCache(t, srv, nil)
+	ctx := context.Background()
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1"}}}, nil
+	}
+	c.GetOrCompute(ctx, "shared", fn)
+	// computed by the local fallback while the backend was down
+	c.local.GetOrCompute(ctx, "local", fn)
+
+	entries, err := c.List(ctx, "")
+	if err != nil || len(entries) != 2 || entries[0].Key != "local" || entries[1].Key != "shared" {
+		t.Fatalf("expected both entries listed, got %+v (%v)", entries, err)
+	}
+	if entry, ok, err := c.Get(ctx, "local"); err != nil || !ok || entry.Result == nil {
+		t.Fatalf("expected the local entry, got %+v, %v (%v)", entry, ok, err)
+	}
+}
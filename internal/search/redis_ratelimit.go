@@ -0,0 +1,233 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/resp"
+)
+
+// Policies applied by the shared limiter while its store is unreachable.
+const (
+	// StoreFailureLocal limits with the local limiter, so each replica
+	// enforces the plan on its own share of the traffic.
+	StoreFailureLocal = "local"
+	// StoreFailureOpen lets every request through.
+	StoreFailureOpen = "open"
+	// StoreFailureClosed denies every request.
+	StoreFailureClosed = "closed"
+)
+
+// gcraScript runs GCRA and the daily quota in one atomic step, so replicas
+// sharing a store never both spend the last request. Times, including the
+// UTC day of the quota, come from the store's clock, which keeps replicas
+// with skewed clocks consistent.
+//
+// KEYS: tat, quota counter. ARGV: emission interval in microseconds (0 when
+// there is no per minute limit), burst, daily quota (0 when there is none).
+// The quota counter holds "<day>:<used>", days counted from the epoch.
+// Returns {allowed, remaining, reset, retry after, quota used, microseconds
+// until midnight}.
+var gcraScript = resp.NewScript(`
+-- writes after TIME need effects replication, the default from Redis 5
+redis.replicate_commands()
+local t = redis.call('TIME')
+local secs = tonumber(t[1])
+local now = secs * 1000000 + tonumber(t[2])
+local day = math.floor(secs / 86400)
+local midnight = (day + 1) * 86400000000 - now
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local quota = tonumber(ARGV[3])
+
+local used = 0
+if quota > 0 then
+  local stored = redis.call('GET', KEYS[2])
+  if stored then
+    local d, n = string.match(stored, '^(%d+):(%d+)$')
+    if tonumber(d) == day then
+      used = tonumber(n)
+    end
+  end
+  if used >= quota then
+    return {0, 0, 0, 0, used, midnight}
+  end
+end
+
+local allowed, remaining, reset, retry = 1, 0, 0, 0
+if interval > 0 then
+  local tat = tonumber(redis.call('GET', KEYS[1]) or '0')
+  if tat < now then
+    tat = now
+  end
+  local window = interval * burst
+  local ahead = tat + interval - now
+  if ahead > window then
+    allowed = 0
+    retry = ahead - window
+  else
+    tat = tat + interval
+    redis.call('SET', KEYS[1], string.format('%d', tat), 'PX', math.ceil((tat - now) / 1000))
+  end
+  remaining = math.floor((window - (tat - now)) / interval)
+  reset = tat - now
+end
+
+if allowed == 1 and quota > 0 then
+  used = used + 1
+  redis.call('SET', KEYS[2], string.format('%d:%d', day, used), 'PX', math.ceil(midnight / 1000))
+end
+return {allowed, remaining, reset, retry, used, midnight}
+`)
+
+// redisLimiter enforces plans across replicas through a Redis compatible
+// store, always with GCRA for the per minute limit. Whenever the store is
+// unreachable the store failure policy decides instead.
+type redisLimiter struct {
+	client  *resp.Client
+	local   RateLimiter
+	prefix  string
+	failure string
+	timeout time.Duration
+	metrics *obs.Metrics
+	now     func() time.Time
+
+	// After a store failure requests skip the store until downUntil, so an
+	// outage does not cost every request a dial timeout.
+	retryAfter time.Duration
+	mu         sync.Mutex
+	downUntil  time.Time
+}
+
+// RedisLimiterOption configures optional redisLimiter behaviour.
+type RedisLimiterOption func(*redisLimiter)
+
+// WithStoreFailure sets the policy applied while the store is unreachable:
+// StoreFailureLocal, StoreFailureOpen or StoreFailureClosed.
+func WithStoreFailure(policy string) RedisLimiterOption {
+	return func(rl *redisLimiter) { rl.failure = policy }
+}
+
+// WithStoreRetry sets how long the store is bypassed after a failure.
+func WithStoreRetry(d time.Duration) RedisLimiterOption {
+	return func(rl *redisLimiter) { rl.retryAfter = d }
+}
+
+// WithStoreTimeout bounds each call to the store, which every limited
+// request waits on.
+func WithStoreTimeout(d time.Duration) RedisLimiterOption {
+	return func(rl *redisLimiter) { rl.timeout = d }
+}
+
+// NewRedisLimiter returns a limiter sharing its state through client. local
+// is used under StoreFailureLocal, the default policy.
+func NewRedisLimiter(client *resp.Client, local RateLimiter, m *obs.Metrics, opts ...RedisLimiterOption) *redisLimiter {
+	rl := &redisLimiter{
+		client:  client,
+		local:   local,
+		prefix:  "hotel:ratelimit:",
+		failure: StoreFailureLocal,
+		timeout: 100 * time.Millisecond,
+		metrics: m,
+		now:     time.Now,
+
+		retryAfter: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+	return rl
+}
+
+func (rl *redisLimiter) Allow(id string, plan Plan) Decision {
+	if plan.RequestsPerMinute <= 0 && plan.DailyQuota <= 0 {
+		return unlimited
+	}
+	if rl.storeDown() {
+		return rl.fallback(id, plan)
+	}
+
+	var interval time.Duration
+	if plan.RequestsPerMinute > 0 {
+		interval = time.Minute / time.Duration(plan.RequestsPerMinute)
+	}
+	// the hash tag keeps both keys of a client in one cluster slot
+	keys := []string{
+		fmt.Sprintf("%sgcra:{%s}", rl.prefix, id),
+		fmt.Sprintf("%squota:{%s}", rl.prefix, id),
+	}
+	args := []string{
+		strconv.FormatInt(interval.Microseconds(), 10),
+		strconv.Itoa(plan.burst()),
+		strconv.Itoa(plan.DailyQuota),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rl.timeout)
+	defer cancel()
+	reply, err := gcraScript.Run(ctx, rl.client, keys, args...)
+	if err != nil {
+		rl.storeError(err)
+		return rl.fallback(id, plan)
+	}
+	vals, ok := reply.([]any)
+	if !ok || len(vals) != 6 {
+		rl.storeError(fmt.Errorf("unexpected script reply %v", reply))
+		return rl.fallback(id, plan)
+	}
+	n := make([]int64, len(vals))
+	for i, v := range vals {
+		n[i], _ = v.(int64)
+	}
+	allowed, used := n[0] == 1, int(n[4])
+	midnight := time.Duration(n[5]) * time.Microsecond
+
+	if plan.DailyQuota > 0 && !allowed && used >= plan.DailyQuota {
+		return Decision{Limit: plan.DailyQuota, Reset: midnight, RetryAfter: midnight}
+	}
+	d := Decision{Allowed: allowed}
+	if interval > 0 {
+		d.Limit = plan.burst()
+		d.Remaining = int(n[1])
+		d.Reset = time.Duration(n[2]) * time.Microsecond
+		d.RetryAfter = time.Duration(n[3]) * time.Microsecond
+	}
+	// report the quota once it is the tighter limit
+	if left := plan.DailyQuota - used; plan.DailyQuota > 0 && (d.Limit == 0 || left < d.Remaining) {
+		d.Limit, d.Remaining, d.Reset = plan.DailyQuota, left, midnight
+	}
+	return d
+}
+
+func (rl *redisLimiter) fallback(id string, plan Plan) Decision {
+	switch rl.failure {
+	case StoreFailureOpen:
+		return unlimited
+	case StoreFailureClosed:
+		return Decision{RetryAfter: rl.retryAfter}
+	}
+	if rl.local == nil {
+		return unlimited
+	}
+	return rl.local.Allow(id, plan)
+}
+
+func (rl *redisLimiter) storeDown() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.now().Before(rl.downUntil)
+}
+
+func (rl *redisLimiter) storeError(err error) {
+	log.Printf("rate limit store failed: %v", err)
+	if rl.metrics != nil {
+		rl.metrics.IncRateLimitStoreErrors()
+	}
+	rl.mu.Lock()
+	rl.downUntil = rl.now().Add(rl.retryAfter)
+	rl.mu.Unlock()
+}
@@ -0,0 +1,221 @@
+package search
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/example/mini-hotel-aggregator/internal/resp"
+	"github.com/example/mini-hotel-aggregator/internal/resp/resptest"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// registerGCRAScript teaches srv a Go equivalent of gcraScript. Both are
+// checked against redisLimiterCases.
+func registerGCRAScript(srv *resptest.Server) {
+	srv.RegisterScript(gcraScript.Source(), func(db *resptest.DB, keys, args []string) any {
+		num := func(s string) int64 { n, _ := strconv.ParseInt(s, 10, 64); return n }
+		get := func(key string) int64 { v, _ := db.Get(key); return num(v) }
+		secs := db.Now().Unix()
+		now := db.Now().UnixMicro()
+		day := secs / 86400
+		midnight := (day+1)*86400000000 - now
+		interval, burst, quota := num(args[0]), num(args[1]), num(args[2])
+
+		var used int64
+		if quota > 0 {
+			if v, ok := db.Get(keys[1]); ok {
+				if d, n, _ := strings.Cut(v, ":"); num(d) == day {
+					used = num(n)
+				}
+			}
+			if used >= quota {
+				return []any{int64(0), int64(0), int64(0), int64(0), used, midnight}
+			}
+		}
+		allowed, remaining, reset, retry := int64(1), int64(0), int64(0), int64(0)
+		if interval > 0 {
+			tat := max(get(keys[0]), now)
+			window := interval * burst
+			if ahead := tat + interval - now; ahead > window {
+				allowed, retry = 0, ahead-window
+			} else {
+				tat += interval
+				db.Set(keys[0], strconv.FormatInt(tat, 10), time.Duration(tat-now)*time.Microsecond)
+			}
+			remaining, reset = (window-(tat-now))/interval, tat-now
+		}
+		if allowed == 1 && quota > 0 {
+			used++
+			db.Set(keys[1], fmt.Sprintf("%d:%d", day, used), time.Duration(midnight)*time.Microsecond)
+		}
+		return []any{allowed, remaining, reset, retry, used, midnight}
+	})
+}
+
+// gcraScriptSum pins the script registerGCRAScript was written against.
+// When the script changes, update the stand-in, then this sum.
+const gcraScriptSum = "6f2bd484f69e6de4aee07a681a84725a6ad1cfa4"
+
+func TestRedisLimiter_StandInMatchesScript(t *testing.T) {
+	sum := sha1.Sum([]byte(gcraScript.Source()))
+	if got := hex.EncodeToString(sum[:]); got != gcraScriptSum {
+		t.Fatalf("gcraScript changed (sha1 %s): update registerGCRAScript to match, then gcraScriptSum", got)
+	}
+}
+
+// redisLimiterCases are runs of requests from one client, alternating
+// between two replicas, and the decisions they must get.
+var redisLimiterCases = []struct {
+	name  string
+	plan  Plan
+	steps []Decision // Allowed, Limit and Remaining are compared
+}{
+	{"burst", Plan{RequestsPerMinute: 1, Burst: 3}, []Decision{
+		{Allowed: true, Limit: 3, Remaining: 2}, {Allowed: true, Limit: 3, Remaining: 1},
+		{Allowed: true, Limit: 3, Remaining: 0}, {Limit: 3},
+	}},
+	{"daily quota", Plan{DailyQuota: 2}, []Decision{
+		{Allowed: true, Limit: 2, Remaining: 1}, {Allowed: true, Limit: 2}, {Limit: 2},
+	}},
+	{"quota tighter than burst", Plan{RequestsPerMinute: 60, Burst: 10, DailyQuota: 3}, []Decision{
+		{Allowed: true, Limit: 3, Remaining: 2}, {Allowed: true, Limit: 3, Remaining: 1},
+		{Allowed: true, Limit: 3}, {Limit: 3},
+	}},
+	{"burst tighter than quota", Plan{RequestsPerMinute: 1, Burst: 2, DailyQuota: 100}, []Decision{
+		{Allowed: true, Limit: 2, Remaining: 1}, {Allowed: true, Limit: 2}, {Limit: 2},
+	}},
+}
+
+func runRedisLimiterCases(t *testing.T, a, b *redisLimiter) {
+	for _, tc := range redisLimiterCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// a fresh client per run, so a real server needs no cleanup
+			id := uuid.NewString()
+			for i, want := range tc.steps {
+				rl := []*redisLimiter{a, b}[i%2]
+				d := rl.Allow(id, tc.plan)
+				if d.Allowed != want.Allowed || d.Limit != want.Limit || d.Remaining != want.Remaining {
+					t.Fatalf("request %d: expected %+v, got %+v", i, want, d)
+				}
+				if !d.Allowed && (d.RetryAfter <= 0 || d.RetryAfter > 24*time.Hour) {
+					t.Fatalf("request %d: expected a retry within a day, got %v", i, d.RetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisLimiter_Cases(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	registerGCRAScript(srv)
+	runRedisLimiterCases(t, newTestRedisLimiter(t, srv, nil), newTestRedisLimiter(t, srv, nil))
+}
+
+// TestRedisLimiter_Lua runs the cases through the Lua script on a real
+// server, such as REDIS_ADDR=localhost:6379. CI provides one.
+func TestRedisLimiter_Lua(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	client := resp.NewClient(addr)
+	defer client.Close()
+	// a script error denies, rather than hiding behind the local limiter
+	a := NewRedisLimiter(client, nil, nil, WithStoreFailure(StoreFailureClosed))
+	b := NewRedisLimiter(client, nil, nil, WithStoreFailure(StoreFailureClosed))
+	runRedisLimiterCases(t, a, b)
+}
+
+func newTestRedisLimiter(t *testing.T, srv *resptest.Server, m *obs.Metrics, opts ...RedisLimiterOption) *redisLimiter {
+	t.Helper()
+	client := resp.NewClient(srv.Addr(), resp.WithDialTimeout(100*time.Millisecond))
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiter(client, NewGCRALimiter(), m, opts...)
+}
+
+func TestRedisLimiter_SharedBetweenReplicas(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	registerGCRAScript(srv)
+	a, b := newTestRedisLimiter(t, srv, nil), newTestRedisLimiter(t, srv, nil)
+	plan := Plan{RequestsPerMinute: 1, Burst: 3}
+
+	for i, rl := range []*redisLimiter{a, b, a} {
+		if d := rl.Allow("client", plan); !d.Allowed || d.Limit != 3 || d.Remaining != 2-i {
+			t.Fatalf("request %d: expected allowed with %d remaining, got %+v", i, 2-i, d)
+		}
+	}
+	d := b.Allow("client", plan)
+	if d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > time.Minute {
+		t.Fatalf("expected the shared burst to be spent, got %+v", d)
+	}
+	if d := b.Allow("other", plan); !d.Allowed {
+		t.Fatalf("expected clients to be limited separately, got %+v", d)
+	}
+}
+
+func TestRedisLimiter_DailyQuota(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	registerGCRAScript(srv)
+	a, b := newTestRedisLimiter(t, srv, nil), newTestRedisLimiter(t, srv, nil)
+	// the day is the store's, whatever the replica's clock says
+	a.now = func() time.Time { return time.Now().Add(36 * time.Hour) }
+	plan := Plan{DailyQuota: 2}
+
+	if d := a.Allow("client", plan); !d.Allowed || d.Limit != 2 || d.Remaining != 1 {
+		t.Fatalf("expected the quota to be reported, got %+v", d)
+	}
+	if d := b.Allow("client", plan); !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("expected the last request of the quota, got %+v", d)
+	}
+	d := a.Allow("client", plan)
+	if d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > 24*time.Hour {
+		t.Fatalf("expected the quota to be spent until midnight, got %+v", d)
+	}
+	key, want := "hotel:ratelimit:quota:{client}", fmt.Sprintf("%d:2", time.Now().Unix()/86400)
+	if v, ok := srv.Get(key); !ok || v != want {
+		t.Fatalf("expected %s to hold %q, got %q", key, want, v)
+	}
+}
+
+func TestRedisLimiter_StoreFailure(t *testing.T) {
+	plan := Plan{RequestsPerMinute: 1, Burst: 1}
+	tests := []struct {
+		policy string
+		want   []bool
+	}{
+		{StoreFailureLocal, []bool{true, false}},
+		{StoreFailureOpen, []bool{true, true}},
+		{StoreFailureClosed, []bool{false, false}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.policy, func(t *testing.T) {
+			srv := resptest.NewServer()
+			registerGCRAScript(srv)
+			m := obs.NewMetrics(prometheus.NewRegistry())
+			rl := newTestRedisLimiter(t, srv, m, WithStoreFailure(tc.policy))
+			srv.Close()
+
+			for i, want := range tc.want {
+				if d := rl.Allow("client", plan); d.Allowed != want {
+					t.Fatalf("request %d: expected allowed %v, got %+v", i, want, d)
+				}
+			}
+			// the store is skipped after the first failure
+			if got := testutil.ToFloat64(m.RateLimitStoreErrors); got != 1 {
+				t.Fatalf("expected 1 store error, got %v", got)
+			}
+		})
+	}
+}
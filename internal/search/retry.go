@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy retries retryable provider errors with exponential backoff and
+// full jitter. A retry is only attempted if its backoff fits in the time left
+// before the context deadline.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, BaseDelay: 50 * time.Millisecond, MaxDelay: 400 * time.Millisecond}
+}
+
+// backoff returns the delay before retry number attempt (1-based), drawn
+// uniformly from [0, min(MaxDelay, BaseDelay*2^(attempt-1))].
+func (p RetryPolicy) backoff(attempt int, rnd func(int64) int64) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rnd(int64(d) + 1))
+}
+
+// do calls fn until it succeeds, returns a permanent error, the attempts run
+// out or the next backoff would overrun the deadline. onRetry is called before
+// every retry.
+func (p RetryPolicy) do(ctx context.Context, fn func(ctx context.Context) ([]Hotel, error), onRetry func()) ([]Hotel, error) {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		hs, err := fn(ctx)
+		if err == nil {
+			return hs, nil
+		}
+		lastErr = err
+		if attempt == attempts || !IsRetryable(err) || ctx.Err() != nil {
+			break
+		}
+
+		delay := p.backoff(attempt, rand.Int63n)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			break
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, lastErr
+		case <-timer.C:
+		}
+		if onRetry != nil {
+			onRetry()
+		}
+	}
+	return nil, lastErr
+}
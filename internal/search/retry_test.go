@@ -0,0 +1,99 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyProvider fails the first n calls with err, then succeeds.
+type flakyProvider struct {
+	name  string
+	n     int
+	err   error
+	calls int
+}
+
+func (f *flakyProvider) Search(ctx context.Context, req *models.SearchRequest) ([]Hotel, error) {
+	f.calls++
+	if f.calls <= f.n {
+		return nil, f.err
+	}
+	return []Hotel{{HotelID: "H1", Name: "A", Price: 100}}, nil
+}
+func (f *flakyProvider) Name() string { return f.name }
+
+func TestAggregator_RetriesTransientErrors(t *testing.T) {
+	p := &flakyProvider{name: "flaky", n: 2, err: Retryable(errors.New("503"))}
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	agg := NewAggregator([]Provider{p}, time.Second, m, WithRetry(policy))
+
+	res, err := agg.Search(context.Background(), &models.SearchRequest{City: "c", Checkin: "2025-11-20", Nights: 1, Adults: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 3 || res.Stats.ProvidersSucceeded != 1 || len(res.Hotels) != 1 {
+		t.Fatalf("expected success on third attempt, calls=%d stats=%+v", p.calls, res.Stats)
+	}
+	if got := testutil.ToFloat64(m.ProviderRetries.WithLabelValues("flaky")); got != 2 {
+		t.Fatalf("expected 2 retries counted, got %v", got)
+	}
+}
+
+func TestAggregator_DoesNotRetryPermanentErrors(t *testing.T) {
+	p := &flakyProvider{name: "broken", n: 5, err: Permanent(errors.New("401"))}
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	agg := NewAggregator([]Provider{p}, time.Second, obs.NewMetrics(prometheus.NewRegistry()), WithRetry(policy))
+
+	_, err := agg.Search(context.Background(), &models.SearchRequest{City: "c", Checkin: "2025-11-20", Nights: 1, Adults: 1})
+	if p.calls != 1 || !errors.Is(err, ErrAllProvidersFailed) {
+		t.Fatalf("expected a single failed attempt, calls=%d err=%v", p.calls, err)
+	}
+}
+
+func TestRetryPolicy_RespectsDeadline(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 200 * time.Millisecond, MaxDelay: time.Second}
+	calls := 0
+	fn := func(ctx context.Context) ([]Hotel, error) {
+		calls++
+		return nil, Retryable(errors.New("timeout"))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := policy.do(ctx, fn, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("retry overran the deadline: %v", time.Since(start))
+	}
+}
+
+func TestRetryPolicy_BackoffBounds(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	max := func(n int64) int64 { return n - 1 }
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{4, 50 * time.Millisecond},
+		{40, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := policy.backoff(tt.attempt, max); got != tt.want {
+			t.Errorf("attempt %d: expected upper bound %v, got %v", tt.attempt, tt.want, got)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package search
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/example/mini-hotel-aggregator/internal/models"
@@ -30,7 +29,15 @@ func NewService(ag AggregatorService, ch CacheService, m *obs.Metrics, t time.Du
 }
 
 func (s *service) Search(ctx context.Context, req *models.SearchRequest) (AggregatedResult, error) {
-	cacheKey := fmt.Sprintf("%s|%s|%d|%d", req.City, req.Checkin, req.Nights, req.Adults)
+	start := time.Now()
+	// a request without a currency is served in the default one, so both
+	// share a cache entry
+	if d, ok := s.agg.(interface{ DefaultCurrency() string }); ok && req.Currency == "" && d.DefaultCurrency() != "" {
+		resolved := *req
+		resolved.Currency = d.DefaultCurrency()
+		req = &resolved
+	}
+	cacheKey := req.CacheKey()
 
 	// compute with per-request timeout
 	cctx, cancel := context.WithTimeout(ctx, s.computeTimeout)
@@ -43,5 +50,15 @@ func (s *service) Search(ctx context.Context, req *models.SearchRequest) (Aggreg
 		return AggregatedResult{}, err
 	}
 
+	// filters and sorting run on the shared cached result, neither mutates it
+	hotels := SortHotels(ApplyFilters(res.Hotels, req.Filters), req.Page.Sort)
+	page, info, err := paginate(hotels, req.Page, resultGeneration(hotels), queryFingerprint(cacheKey, req))
+	if err != nil {
+		return AggregatedResult{}, err
+	}
+	res.Hotels = page
+	res.Page = &info
+	// the cached duration belongs to whichever request computed the result
+	res.Stats.DurationMs = time.Since(start).Milliseconds()
 	return res, nil
 }
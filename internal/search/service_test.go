@@ -32,6 +32,7 @@ func (m *mockAggregator) Search(ctx context.Context, req *models.SearchRequest)
 }
 
 type mockCache struct {
+	search.CacheService // admin methods are not exercised here
 	getOrComputeFunc func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error)
 }
 
@@ -58,13 +59,7 @@ func TestService_Search_Success(t *testing.T) {
 			aggCalled = true
 			return search.AggregatedResult{
 				Hotels: []search.Hotel{{HotelID: "H1", Name: "A", Price: 100, Nights: req.Nights}},
-				Stats: struct {
-					ProvidersTotal     int    "json:\"providers_total\""
-					ProvidersSucceeded int    "json:\"providers_succeeded\""
-					ProvidersFailed    int    "json:\"providers_failed\""
-					Cache              string "json:\"cache\""
-					DurationMs         int64  "json:\"duration_ms\""
-				}{ProvidersTotal: 1, ProvidersSucceeded: 1, ProvidersFailed: 0, Cache: "miss"},
+				Stats: search.SearchStats{ProvidersTotal: 1, ProvidersSucceeded: 1, ProvidersFailed: 0, Cache: "miss"},
 			}, nil
 		},
 	}
@@ -189,3 +184,67 @@ func TestService_Search_ConcurrentRequests(t *testing.T) {
 		t.Fatalf("expected aggregator to be called 5 times (no caching collapse here), got %d", agg.counter)
 	}
 }
+
+func TestService_Search_FiltersDoNotFragmentCache(t *testing.T) {
+	var keys []string
+	cached := search.AggregatedResult{Hotels: []search.Hotel{
+		{HotelID: "H1", Name: "Hotel Atlas", Price: 120, Stars: 4},
+		{HotelID: "H2", Name: "Riad Sunset", Price: 90, Stars: 3},
+	}}
+	cache := &mockCache{
+		getOrComputeFunc: func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error) {
+			keys = append(keys, key)
+			return cached, nil
+		},
+	}
+	svc := search.NewService(&mockAggregator{}, cache, obs.NewMetrics(prometheus.NewRegistry()), 2*time.Second)
+
+	req := &models.SearchRequest{City: "NYC", Checkin: "2025-11-20", Nights: 2, Adults: 2}
+	all, _ := svc.Search(context.Background(), req)
+
+	req.Filters = models.SearchFilters{Stars: []int{4}}
+	filtered, err := svc.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Hotels) != 2 || len(filtered.Hotels) != 1 || filtered.Hotels[0].HotelID != "H1" {
+		t.Fatalf("unexpected results: all=%+v filtered=%+v", all.Hotels, filtered.Hotels)
+	}
+	if keys[0] != keys[1] {
+		t.Fatalf("filters changed the cache key: %q vs %q", keys[0], keys[1])
+	}
+	if len(cached.Hotels) != 2 {
+		t.Fatal("cached result was modified")
+	}
+}
+
+type identityConverter struct{}
+
+func (identityConverter) Supports(code string) bool { return code == "EUR" }
+func (identityConverter) Convert(amount float64, from, to string) (float64, error) {
+	return amount, nil
+}
+
+func TestService_Search_DefaultCurrencySharesCacheKey(t *testing.T) {
+	var keys []string
+	cache := &mockCache{
+		getOrComputeFunc: func(ctx context.Context, key string, fn func(ctx context.Context) (search.AggregatedResult, error)) (search.AggregatedResult, error) {
+			keys = append(keys, key)
+			return fn(ctx)
+		},
+	}
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	agg := search.NewAggregator(nil, time.Second, m, search.WithCurrencyConverter(identityConverter{}, "EUR"))
+	svc := search.NewService(agg, cache, m, time.Second)
+
+	for _, currency := range []string{"", "EUR"} {
+		req := &models.SearchRequest{City: "rome", Checkin: "2025-11-20", Nights: 1, Adults: 2, Currency: currency}
+		res, err := svc.Search(context.Background(), req)
+		if err != nil || res.Currency != "EUR" {
+			t.Fatalf("currency %q: expected a EUR result, got %q (%v)", currency, res.Currency, err)
+		}
+	}
+	if keys[0] != keys[1] {
+		t.Fatalf("expected the default currency to share a cache key: %q vs %q", keys[0], keys[1])
+	}
+}
@@ -0,0 +1,128 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+)
+
+const snapshotVersion = 1
+
+// ErrIncompatibleSnapshot is returned when a snapshot was written with a
+// different encoding or cache key version; it is ignored, not loaded.
+var ErrIncompatibleSnapshot = errors.New("incompatible cache snapshot")
+
+type snapshotFile struct {
+	Version    int             `json:"v"`
+	KeyVersion int             `json:"key_version"`
+	SavedAt    time.Time       `json:"saved_at"`
+	Entries    []snapshotEntry `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Key string `json:"key"`
+	cacheEnvelope
+}
+
+// SaveSnapshot writes every entry that could still be served to path, most
+// recently used first, and reports how many were written. The file is
+// replaced atomically so a crash never leaves a truncated snapshot.
+func (c *cache) SaveSnapshot(path string) (int, error) {
+	c.mu.Lock()
+	now := c.now()
+	snap := snapshotFile{Version: snapshotVersion, KeyVersion: models.CacheKeyVersion, SavedAt: now}
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*cacheEntry)
+		// negative entries are short lived and not worth keeping
+		if !e.ready || e.err != nil || !now.Before(c.servableUntil(e)) {
+			continue
+		}
+		if emptyResult(e.val) && c.negativeTTL > 0 {
+			continue
+		}
+		snap.Entries = append(snap.Entries, snapshotEntry{Key: e.key, cacheEnvelope: cacheEnvelope{
+			Version:  cacheEnvelopeVersion,
+			StoredAt: e.storedAt,
+			Expiry:   e.expiry,
+			Result:   e.val,
+			Warmed:   e.warmed,
+		}})
+	}
+	c.mu.Unlock()
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(path, b); err != nil {
+		return 0, err
+	}
+	return len(snap.Entries), nil
+}
+
+// LoadSnapshot adds the entries saved in path that can still be served and
+// reports how many were loaded. Keys already cached are left alone and the
+// cache bounds still apply. A missing file loads nothing.
+func (c *cache) LoadSnapshot(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var snap snapshotFile
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrIncompatibleSnapshot, err)
+	}
+	if snap.Version != snapshotVersion || snap.KeyVersion != models.CacheKeyVersion {
+		return 0, fmt.Errorf("%w: version %d, key version %d", ErrIncompatibleSnapshot, snap.Version, snap.KeyVersion)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	loaded := 0
+	for _, s := range snap.Entries {
+		if _, found := c.items[s.Key]; found || s.Version != cacheEnvelopeVersion {
+			continue
+		}
+		e := &cacheEntry{key: s.Key, expiry: s.Expiry}
+		if !now.Before(c.servableUntil(e)) {
+			continue
+		}
+		// entries are saved most recent first, so appending keeps LRU order
+		e.elem = c.lru.PushBack(e)
+		c.items[s.Key] = e
+		c.storeLocked(e, s.Result, nil, s.StoredAt, s.Expiry.Sub(s.StoredAt), s.Warmed)
+		loaded++
+	}
+	c.evictLocked(nil)
+	c.reportSizeLocked()
+	return loaded, nil
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
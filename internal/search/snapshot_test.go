@@ -0,0 +1,77 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, clk := newTestCache(WithStaleWhileRevalidate(5 * time.Second))
+	fn := func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{Hotels: []Hotel{{HotelID: "h1", Price: 100}}}, nil
+	}
+	c.GetOrCompute(context.Background(), "old", fn)
+	clk.Advance(20 * time.Second) // past ttl and stale window
+	c.GetOrCompute(context.Background(), "fresh", fn)
+	c.GetOrCompute(context.Background(), "failed", func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{}, errors.New("boom")
+	})
+
+	if n, err := c.SaveSnapshot(path); err != nil || n != 1 {
+		t.Fatalf("expected 1 entry saved, got %d (%v)", n, err)
+	}
+
+	restored, rclk := newTestCache(WithStaleWhileRevalidate(5 * time.Second))
+	rclk.t = clk.t.Add(4 * time.Second)
+	if n, err := restored.LoadSnapshot(path); err != nil || n != 1 {
+		t.Fatalf("expected 1 entry loaded, got %d (%v)", n, err)
+	}
+	res, err := restored.GetOrCompute(context.Background(), "fresh", func(ctx context.Context) (AggregatedResult, error) {
+		t.Fatal("restored entry should be served without recomputing")
+		return AggregatedResult{}, nil
+	})
+	if err != nil || res.Stats.Cache != CacheHit || res.Stats.CacheAgeMs != 4000 || len(res.Hotels) != 1 {
+		t.Fatalf("expected restored hit aged 4s, got %+v (%v)", res.Stats, err)
+	}
+}
+
+func TestCacheSnapshot_SkipsEmptyResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c, _ := newTestCache(WithNegativeTTL(time.Second), WithStaleWhileRevalidate(5*time.Second))
+	c.GetOrCompute(context.Background(), "empty", func(ctx context.Context) (AggregatedResult, error) {
+		return AggregatedResult{}, nil
+	})
+
+	// stored with the negative ttl, so not worth restoring
+	if n, err := c.SaveSnapshot(path); err != nil || n != 0 {
+		t.Fatalf("expected no entry saved, got %d (%v)", n, err)
+	}
+}
+
+func TestCacheSnapshot_DiscardsIncompatible(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := newTestCache()
+
+	if n, err := c.LoadSnapshot(filepath.Join(dir, "missing.json")); err != nil || n != 0 {
+		t.Fatalf("expected a missing snapshot to load nothing, got %d (%v)", n, err)
+	}
+	for name, body := range map[string]string{
+		"old.json":     `{"v":0,"key_version":1,"entries":[{"key":"k","v":1}]}`,
+		"keys.json":    `{"v":1,"key_version":0,"entries":[{"key":"k","v":1}]}`,
+		"garbage.json": `not json`,
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(body), 0o644)
+		if n, err := c.LoadSnapshot(path); !errors.Is(err, ErrIncompatibleSnapshot) || n != 0 {
+			t.Fatalf("%s: expected incompatible snapshot, got %d (%v)", name, n, err)
+		}
+	}
+	if entries, _ := c.List(context.Background(), ""); len(entries) != 0 {
+		t.Fatalf("expected nothing loaded, got %v", entries)
+	}
+}
@@ -2,10 +2,14 @@ package search
 
 import (
 	"context"
+	"time"
 
 	"github.com/example/mini-hotel-aggregator/internal/models"
 )
 
+// Hotel is both what a provider returns and what the aggregator serves. In the
+// aggregated result, Price/Currency/Provider and the rate conditions describe
+// the cheapest offer and Offers lists every supplier's offer.
 type Hotel struct {
 	HotelID  string  `json:"hotel_id"`
 	Name     string  `json:"name"`
@@ -13,6 +17,33 @@ type Hotel struct {
 	Currency string  `json:"currency"`
 	Price    float64 `json:"price"`
 	Nights   int     `json:"nights"`
+	Provider string  `json:"provider,omitempty"`
+
+	Stars     int      `json:"stars,omitempty"`
+	Amenities []string `json:"amenities,omitempty"`
+	// Rating is the guest review score, 0..10.
+	Rating float64 `json:"rating,omitempty"`
+
+	// rate conditions
+	Refundable bool   `json:"refundable,omitempty"`
+	Board      string `json:"board,omitempty"`
+
+	Offers []Offer `json:"offers,omitempty"`
+}
+
+// Offer is one supplier's rate for a hotel.
+type Offer struct {
+	Provider string `json:"provider"`
+	// HotelID is the supplier's own id for the hotel.
+	HotelID    string  `json:"provider_hotel_id,omitempty"`
+	Price      float64 `json:"price"`
+	Currency   string  `json:"currency"`
+	Refundable bool    `json:"refundable"`
+	Board      string  `json:"board,omitempty"`
+
+	// set when Price was converted from the supplier's currency
+	OriginalPrice    float64 `json:"original_price,omitempty"`
+	OriginalCurrency string  `json:"original_currency,omitempty"`
 }
 
 type ProviderResult struct {
@@ -20,15 +51,32 @@ type ProviderResult struct {
 	Hotels   []Hotel
 }
 
+type SearchStats struct {
+	ProvidersTotal     int `json:"providers_total"`
+	ProvidersSucceeded int `json:"providers_succeeded"`
+	ProvidersFailed    int `json:"providers_failed"`
+	// ProvidersSkipped counts providers not called because their circuit was open.
+	ProvidersSkipped int `json:"providers_skipped"`
+	// ProvidersCached counts succeeded providers whose answer came from the
+	// provider cache instead of a call.
+	ProvidersCached int    `json:"providers_cached"`
+	Cache           string `json:"cache"`
+	// CacheAgeMs is how long ago the served result was computed; CacheTTLMs
+	// is how much longer it stays fresh, negative once it is stale.
+	CacheAgeMs int64 `json:"cache_age_ms"`
+	CacheTTLMs int64 `json:"cache_ttl_ms"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
 type AggregatedResult struct {
-	Stats struct {
-		ProvidersTotal     int    `json:"providers_total"`
-		ProvidersSucceeded int    `json:"providers_succeeded"`
-		ProvidersFailed    int    `json:"providers_failed"`
-		Cache              string `json:"cache"`
-		DurationMs         int64  `json:"duration_ms"`
-	} `json:"stats"`
-	Hotels []Hotel `json:"hotels"`
+	Stats SearchStats `json:"stats"`
+	// Currency all prices are expressed in, empty if no conversion was done.
+	Currency string  `json:"currency,omitempty"`
+	Hotels   []Hotel `json:"hotels"`
+	// ComputedAt is when the providers were queried.
+	ComputedAt time.Time `json:"computed_at"`
+	// Page is set by the service on paged responses.
+	Page *PageInfo `json:"page,omitempty"`
 }
 
 type Provider interface {
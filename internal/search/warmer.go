@@ -0,0 +1,159 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+)
+
+// WarmTemplate describes a family of popular searches to keep cached: one
+// search per check-in offset (in days from today).
+type WarmTemplate struct {
+	City           string `json:"city"`
+	CheckinOffsets []int  `json:"checkin_offsets"`
+	Nights         int    `json:"nights"`
+	Adults         int    `json:"adults"`
+	Currency       string `json:"currency,omitempty"`
+}
+
+// LoadWarmTemplates reads a JSON array of WarmTemplate.
+func LoadWarmTemplates(path string) ([]WarmTemplate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var templates []WarmTemplate
+	if err := json.Unmarshal(b, &templates); err != nil {
+		return nil, fmt.Errorf("parse warm templates %s: %w", path, err)
+	}
+	return templates, nil
+}
+
+type warmingKey struct{}
+
+// withWarming marks ctx as belonging to the warmer. The cache recomputes
+// warming searches whose entry expires within margin, and tags what they
+// store so later user hits can be counted as warm hits.
+func withWarming(ctx context.Context, margin time.Duration) context.Context {
+	return context.WithValue(ctx, warmingKey{}, margin)
+}
+
+// warmMargin returns the margin of a warming search, and false for other
+// searches.
+func warmMargin(ctx context.Context) (time.Duration, bool) {
+	m, ok := ctx.Value(warmingKey{}).(time.Duration)
+	return m, ok
+}
+
+func isWarming(ctx context.Context) bool {
+	_, ok := warmMargin(ctx)
+	return ok
+}
+
+// Warmer periodically runs popular searches through the service so users
+// find them cached.
+type Warmer struct {
+	svc         ServiceManagement
+	templates   []WarmTemplate
+	concurrency int
+	metrics     *obs.Metrics
+	now         func() time.Time
+	// margin is how close to expiry a cached search must be to be refreshed.
+	margin time.Duration
+}
+
+func NewWarmer(svc ServiceManagement, templates []WarmTemplate, concurrency int, m *obs.Metrics) *Warmer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Warmer{svc: svc, templates: templates, concurrency: concurrency, metrics: m, now: time.Now}
+}
+
+// Run warms immediately and then every interval until ctx is cancelled. Each
+// run refreshes the searches that would expire before the next one, so the
+// interval should be shorter than the cache TTL.
+func (w *Warmer) Run(ctx context.Context, interval time.Duration) {
+	w.margin = interval
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		w.WarmOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// WarmOnce runs every templated search, at most concurrency at a time, and
+// reports how many succeeded and failed. Searches cached for longer than the
+// margin are left alone and count as warmed. Searches not started before ctx is
+// cancelled are not counted.
+func (w *Warmer) WarmOnce(ctx context.Context) (warmed, failed int) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, w.concurrency)
+
+loop:
+	for _, req := range w.requests() {
+		select {
+		case <-ctx.Done():
+			break loop
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(req *models.SearchRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, err := w.svc.Search(withWarming(ctx, w.margin), req)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				log.Printf("cache warm %s %s failed: %v", req.City, req.Checkin, err)
+				return
+			}
+			warmed++
+		}(req)
+	}
+	wg.Wait()
+
+	if w.metrics != nil {
+		w.metrics.IncCacheWarmRun(warmed, failed)
+	}
+	return warmed, failed
+}
+
+// requests expands the templates into concrete searches relative to today,
+// skipping (and logging) invalid ones.
+func (w *Warmer) requests() []*models.SearchRequest {
+	today := w.now()
+	var out []*models.SearchRequest
+	for _, t := range w.templates {
+		for _, offset := range t.CheckinOffsets {
+			req := &models.SearchRequest{
+				City:     t.City,
+				Checkin:  today.AddDate(0, 0, offset).Format("2006-01-02"),
+				Nights:   t.Nights,
+				Adults:   t.Adults,
+				Currency: t.Currency,
+			}
+			// Validate normalizes the request the same way user searches are,
+			// so both end up under the same cache key.
+			if err := req.Validate(); err != nil {
+				log.Printf("skipping warm template %+v: %v", t, err)
+				continue
+			}
+			out = append(out, req)
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/example/mini-hotel-aggregator/internal/models"
+	"github.com/example/mini-hotel-aggregator/internal/obs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// recordingService records warm searches and tracks peak concurrency.
+type recordingService struct {
+	mu       sync.Mutex
+	keys     []string
+	inFlight int32
+	peak     int32
+}
+
+func (s *recordingService) Search(ctx context.Context, req *models.SearchRequest) (AggregatedResult, error) {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	s.mu.Lock()
+	if n > s.peak {
+		s.peak = n
+	}
+	s.keys = append(s.keys, fmt.Sprintf("%s|%s|%d|%d|%s", req.City, req.Checkin, req.Nights, req.Adults, req.Currency))
+	s.mu.Unlock()
+	if !isWarming(ctx) {
+		panic("warm search without warming context")
+	}
+	time.Sleep(10 * time.Millisecond)
+	return AggregatedResult{}, nil
+}
+
+func TestWarmer_ExpandsTemplates(t *testing.T) {
+	svc := &recordingService{}
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	w := NewWarmer(svc, []WarmTemplate{
+		{City: " Paris ", CheckinOffsets: []int{1, 7}, Nights: 2, Adults: 2},
+		{City: "rome", CheckinOffsets: []int{0}, Nights: 1, Adults: 1, Currency: "usd"},
+		{City: "x", CheckinOffsets: []int{1}, Nights: 1, Adults: 1}, // invalid city, skipped
+	}, 2, m)
+	w.now = func() time.Time { return time.Date(2025, 12, 30, 10, 0, 0, 0, time.UTC) }
+
+	warmed, failed := w.WarmOnce(context.Background())
+	if warmed != 3 || failed != 0 {
+		t.Fatalf("expected 3 warmed searches, got %d warmed %d failed", warmed, failed)
+	}
+	sort.Strings(svc.keys)
+	want := []string{"paris|2025-12-31|2|2|", "paris|2026-01-06|2|2|", "rome|2025-12-30|1|1|USD"}
+	for i := range want {
+		if svc.keys[i] != want[i] {
+			t.Fatalf("expected keys %v, got %v", want, svc.keys)
+		}
+	}
+	if svc.peak > 2 {
+		t.Fatalf("expected at most 2 concurrent searches, saw %d", svc.peak)
+	}
+	if got := testutil.ToFloat64(m.CacheWarmSearches.WithLabelValues("ok")); got != 3 {
+		t.Fatalf("expected 3 ok warm searches in metrics, got %v", got)
+	}
+}
+
+func TestWarmer_PopulatesCacheAndCountsWarmHits(t *testing.T) {
+	m := obs.NewMetrics(prometheus.NewRegistry())
+	var aggCalls int32
+	agg := &countingAggregator{calls: &aggCalls}
+	c := NewCache(time.Minute, m)
+	clk := &fakeClock{t: time.Now()}
+	c.now = clk.Now
+	svc := NewService(agg, c, m, time.Second)
+	w := NewWarmer(svc, []WarmTemplate{{City: "paris", CheckinOffsets: []int{1}, Nights: 1, Adults: 2}}, 1, m)
+	w.margin = 25 * time.Second
+
+	w.WarmOnce(context.Background())
+	req := w.requests()[0]
+	res, err := svc.Search(context.Background(), req)
+	if err != nil || res.Stats.Cache != CacheHit {
+		t.Fatalf("expected user search to hit the warmed entry, got %q (%v)", res.Stats.Cache, err)
+	}
+	if got := testutil.ToFloat64(m.CacheWarmHits); got != 1 {
+		t.Fatalf("expected 1 warm hit, got %v", got)
+	}
+
+	// a fresh entry is left alone until it would expire before the next run
+	w.WarmOnce(context.Background())
+	if n := atomic.LoadInt32(&aggCalls); n != 1 {
+		t.Fatalf("expected a fresh entry not to be recomputed, got %d aggregator calls", n)
+	}
+	clk.Advance(40 * time.Second)
+	w.WarmOnce(context.Background())
+	if n := atomic.LoadInt32(&aggCalls); n != 2 {
+		t.Fatalf("expected an entry close to expiry to be recomputed, got %d aggregator calls", n)
+	}
+}
+
+type countingAggregator struct{ calls *int32 }
+
+func (a *countingAggregator) Search(ctx context.Context, req *models.SearchRequest) (AggregatedResult, error) {
+	atomic.AddInt32(a.calls, 1)
+	return AggregatedResult{Hotels: []Hotel{{HotelID: "h1", Price: 100}}, ComputedAt: time.Now()}, nil
+}
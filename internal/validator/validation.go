@@ -21,3 +21,21 @@ func ValidateDate(dateStr string) (time.Time, error) {
 	}
 	return t, nil
 }
+
+// ValidateCurrency accepts an empty value (use the default) or a three-letter
+// ISO 4217 code, returned upper-cased.
+func ValidateCurrency(s string) (string, error) {
+	c := strings.ToUpper(strings.TrimSpace(s))
+	if c == "" {
+		return "", nil
+	}
+	if len(c) != 3 {
+		return "", errors.New("invalid currency")
+	}
+	for _, r := range c {
+		if r < 'A' || r > 'Z' {
+			return "", errors.New("invalid currency")
+		}
+	}
+	return c, nil
+}